@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxAliasSteps caps how many commands one alias can run.
+const maxAliasSteps = 10
+
+var (
+	aliasName        = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+	aliasPlaceholder = regexp.MustCompile(`\$(\d|@|\$)`)
+)
+
+// lookupAlias returns the expansion of the user-defined alias name.
+func lookupAlias(name string) (string, bool) {
+	expansion, ok := currentConfig().Aliases[strings.ToLower(name)]
+	return expansion, ok
+}
+
+// expandAlias turns an alias's expansion into the command lines it runs, with
+// the arguments it was given in line. Steps are separated by ; and a \; is a
+// literal semicolon. $1 to $9 are replaced by the alias's arguments, $@ by
+// all of them as given and $$ by a dollar sign. An expansion without any
+// placeholders gets the arguments added to the end of its last step.
+func expandAlias(expansion, line string) []string {
+	line = strings.TrimSpace(line)
+
+	var args []string
+	for _, token := range splitArgs(line) {
+		args = append(args, token.value)
+	}
+
+	steps := splitAliasSteps(expansion)
+	if !aliasPlaceholder.MatchString(expansion) {
+		if line != "" {
+			steps[len(steps)-1] += " " + line
+		}
+		return steps
+	}
+
+	for i, step := range steps {
+		// Each step is filled in on its own, so a ; in the arguments can't
+		// start another step.
+		steps[i] = aliasPlaceholder.ReplaceAllStringFunc(step, func(placeholder string) string {
+			switch placeholder[1] {
+			case '@':
+				return line
+			case '$':
+				return "$"
+			}
+			n, _ := strconv.Atoi(placeholder[1:])
+			if n == 0 || n > len(args) {
+				return ""
+			}
+			return quoteArg(args[n-1])
+		})
+	}
+
+	return steps
+}
+
+func splitAliasSteps(expansion string) []string {
+	var steps []string
+	var step strings.Builder
+
+	for i := 0; i < len(expansion); i++ {
+		switch {
+		case expansion[i] == '\\' && i+1 < len(expansion) && expansion[i+1] == ';':
+			step.WriteByte(';')
+			i++
+		case expansion[i] == ';':
+			if s := strings.TrimSpace(step.String()); s != "" {
+				steps = append(steps, s)
+			}
+			step.Reset()
+		default:
+			step.WriteByte(expansion[i])
+		}
+	}
+	if s := strings.TrimSpace(step.String()); s != "" || len(steps) == 0 {
+		steps = append(steps, s)
+	}
+
+	return steps
+}
+
+// quoteArg quotes an argument that has to stay one word when it's put back
+// into a command line.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, `"'\`) && strings.IndexFunc(arg, unicode.IsSpace) < 0 {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// validateAlias checks that name is free to use as an alias and that every
+// step of expansion runs a command, with or without prefix in front of it.
+func validateAlias(prefix, name, expansion string) error {
+	if !aliasName.MatchString(name) {
+		return errors.New("alias names can only have letters, numbers, - and _")
+	}
+	if _, ok := lookupCommand(name); ok {
+		return fmt.Errorf("%s%s is already a command", prefix, name)
+	}
+
+	steps := splitAliasSteps(expansion)
+	if len(steps) > maxAliasSteps {
+		return fmt.Errorf("aliases can run at most %d commands", maxAliasSteps)
+	}
+	for _, step := range steps {
+		name, _ := splitCommand(strings.TrimPrefix(step, prefix))
+		if name == "" {
+			return errors.New("aliases can't have empty steps")
+		}
+		if _, ok := lookupCommand(name); !ok {
+			return fmt.Errorf("%q isn't a command, and aliases can't run other aliases", name)
+		}
+	}
+
+	return nil
+}
+
+// setAlias adds, replaces or, with an empty expansion, removes an alias and
+// saves the config.
+func setAlias(name, expansion string) error {
+	return configStore.Update(func(cfg *Config) error {
+		if expansion == "" {
+			delete(cfg.Aliases, name)
+			return nil
+		}
+
+		if cfg.Aliases == nil {
+			cfg.Aliases = make(map[string]string)
+		}
+		cfg.Aliases[name] = expansion
+		return nil
+	})
+}
+
+func handleAlias(message Message, args *Args) {
+	prefix := currentConfig().Prefix
+	name := strings.ToLower(strings.TrimPrefix(args.String("name"), prefix))
+
+	switch strings.ToLower(args.String("action")) {
+	case "list":
+		handleAliasList(message)
+
+	case "add":
+		expansion := strings.TrimSpace(args.String("command"))
+		if name == "" || expansion == "" {
+			sendUsageError(message, &UsageError{commandMap["alias"], "add needs a name and the command(s) it runs"})
+			return
+		}
+		if err := validateAlias(prefix, name, expansion); err != nil {
+			reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ %s```", err))
+			return
+		}
+		if err := setAlias(name, expansion); err != nil {
+			reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error saving alias: %s```", err))
+			return
+		}
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ %s%s now runs: %s```", prefix, name, expansion))
+
+	case "remove":
+		if _, ok := lookupAlias(name); !ok {
+			reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ There's no alias called %s```", name))
+			return
+		}
+		if err := setAlias(name, ""); err != nil {
+			reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error saving aliases: %s```", err))
+			return
+		}
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🗑️ Removed %s%s```", prefix, name))
+
+	default:
+		sendUsageError(message, &UsageError{commandMap["alias"], fmt.Sprintf("Unknown action %q", args.String("action"))})
+	}
+}
+
+func handleAliasList(message Message) {
+	cfg := currentConfig()
+
+	names := make([]string, 0, len(cfg.Aliases))
+	for name := range cfg.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nNo aliases yet. Add one with %salias add <name> <command>```", cfg.Prefix))
+		return
+	}
+
+	var list strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&list, "\u001b[0;32m%s%s\u001b[0m → %s\n", cfg.Prefix, name, cfg.Aliases[name])
+	}
+
+	reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAliases:\n"+list.String()+"```")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExpandAlias(t *testing.T) {
+	tests := []struct {
+		expansion, line string
+		want            []string
+	}{
+		{"weather Amsterdam", "", []string{"weather Amsterdam"}},
+		{"weather", " Paris", []string{"weather Paris"}},
+		{"status online; status online Working;say --channel <#123456789012345678> Good morning", "",
+			[]string{"status online", "status online Working", "say --channel <#123456789012345678> Good morning"}},
+		{"say $2 and $1", `one "two words"`, []string{`say "two words" and one`}},
+		{"say $1$3", "a", []string{"say a"}},
+		{"say [$@]; say done", `a  "b; c"`, []string{`say [a  "b; c"]`, "say done"}},
+		{`say costs $$5\; cheap`, "", []string{"say costs $5; cheap"}},
+		{"say hi; ;", "", []string{"say hi"}},
+	}
+
+	for _, test := range tests {
+		if got := expandAlias(test.expansion, test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expandAlias(%q, %q) = %q, want %q", test.expansion, test.line, got, test.want)
+		}
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	valid := map[string]string{
+		"w":       "weather Amsterdam",
+		"morning": "&status online; say --channel 123456789012345678 gm $@",
+	}
+	for name, expansion := range valid {
+		if err := validateAlias("&", name, expansion); err != nil {
+			t.Errorf("%s → %q: %v", name, expansion, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"ping":      "weather",
+		"has space": "weather",
+		"loop":      "loop",
+		"empty":     ";",
+		"typo":      "wether",
+	}
+	for name, expansion := range invalid {
+		if err := validateAlias("&", name, expansion); err == nil {
+			t.Errorf("%s → %q was allowed", name, expansion)
+		}
+	}
+}
+
+func TestMacroCooldownCoversTheWholeRun(t *testing.T) {
+	saved := configStore
+	configStore = NewConfigStore("")
+	defer func() { configStore = saved }()
+	configStore.Replace(Config{Prefix: "&", Aliases: map[string]string{"morning": "status online; status online Working"}})
+
+	status, _ := lookupCommand("status")
+	t.Cleanup(func() {
+		cooldownMutex.Lock()
+		delete(lastRun, status)
+		cooldownMutex.Unlock()
+	})
+
+	var (
+		mu       sync.Mutex
+		statuses []string
+		replies  []string
+		next     = 200000000000000000
+	)
+
+	defaultREST := rest
+	rest = newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var body struct {
+			Content      string `json:"content"`
+			CustomStatus *struct {
+				Text string `json:"text"`
+			} `json:"custom_status"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch {
+		case r.URL.Path == "/users/@me/settings":
+			text := ""
+			if body.CustomStatus != nil {
+				text = body.CustomStatus.Text
+			}
+			statuses = append(statuses, text)
+		case r.Method == http.MethodPost:
+			replies = append(replies, body.Content)
+		}
+
+		next++
+		json.NewEncoder(w).Encode(Message{ID: fmt.Sprint(next), ChannelID: "1"})
+	})
+	t.Cleanup(func() { rest = defaultREST })
+
+	handleMessage(Message{ID: "100000000000000001", ChannelID: "1", Content: "&morning"})
+
+	mu.Lock()
+	if want := []string{"", "Working"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("set statuses %q, want %q", statuses, want)
+	}
+	statuses, replies = nil, nil
+	mu.Unlock()
+
+	// The run as a whole put status on cooldown.
+	handleMessage(Message{ID: "100000000000000002", ChannelID: "1", Content: "&morning"})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(statuses) != 0 || len(replies) != 1 || !strings.Contains(replies[0], "is on cooldown") {
+		t.Errorf("a second run set statuses %q and replied %q", statuses, replies)
+	}
+}
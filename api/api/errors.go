@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fasthttp/websocket"
+	"github.com/switchupcb/dasgo/dasgo"
+)
+
+// CloseError is reported when the gateway connection is closed with a close frame.
+type CloseError struct {
+	Code        int    // Code is the WebSocket close code.
+	Description string // Description is Discord's short description of the code, if it is a gateway close code.
+	Explanation string // Explanation is Discord's longer explanation of the code, if it is a gateway close code.
+	Reconnect   bool   // Reconnect is true if the session can be resumed after the close.
+	Err         error  // Err is the underlying WebSocket error.
+}
+
+func (err *CloseError) Error() string {
+	if err.Description == "" {
+		return fmt.Sprintf("gateway closed with code %d", err.Code)
+	}
+
+	return fmt.Sprintf("gateway closed with code %d: %s - %s", err.Code, err.Description, err.Explanation)
+}
+
+func (err *CloseError) Unwrap() error {
+	return err.Err
+}
+
+// DecodeError is reported when a gateway payload can't be decoded. The event is skipped, and the connection is kept.
+type DecodeError struct {
+	Event   string // Event is the gateway event name, or empty if the payload could not be read far enough to find it.
+	Payload []byte // Payload is the raw payload that failed to decode.
+	Err     error  // Err is the underlying decode error.
+}
+
+func (err *DecodeError) Error() string {
+	if err.Event == "" {
+		return fmt.Sprintf("failed to decode gateway payload: %v", err.Err)
+	}
+
+	return fmt.Sprintf("failed to decode %s event: %v", err.Event, err.Err)
+}
+
+func (err *DecodeError) Unwrap() error {
+	return err.Err
+}
+
+// InvalidSessionError is reported when Discord invalidates the session with an Invalid Session (opcode 9) payload.
+type InvalidSessionError struct {
+	Resumable bool // Resumable is true if the session can be resumed, and false if the client has to identify again.
+}
+
+func (err *InvalidSessionError) Error() string {
+	if err.Resumable {
+		return "gateway session invalidated (resumable)"
+	}
+
+	return "gateway session invalidated"
+}
+
+// RESTError is returned when the REST API answers with a status outside 2xx.
+type RESTError struct {
+	Method     string `json:"-"`       // Method is the HTTP method of the request.
+	Path       string `json:"-"`       // Path is the request path, relative to the API's base URL.
+	StatusCode int    `json:"-"`       // StatusCode is the HTTP status of the response.
+	Code       int    `json:"code"`    // Code is Discord's JSON error code, or 0 if the response didn't include one.
+	Message    string `json:"message"` // Message is Discord's description of the error, if the response included one.
+}
+
+func (err *RESTError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("%s %s: status %d", err.Method, err.Path, err.StatusCode)
+	}
+
+	return fmt.Sprintf("%s %s: status %d: %s (code %d)", err.Method, err.Path, err.StatusCode, err.Message, err.Code)
+}
+
+// newCloseError converts a WebSocket close error into a CloseError, filling in Discord's description of the code.
+func newCloseError(err *websocket.CloseError) *CloseError {
+	closeError := &CloseError{
+		Code: err.Code,
+		Err:  err,
+	}
+
+	if closeEvent, ok := dasgo.GatewayCloseEventCodes[err.Code]; ok {
+		closeError.Description = closeEvent.Description
+		closeError.Explanation = closeEvent.Explanation
+		closeError.Reconnect = closeEvent.Reconnect
+	}
+
+	return closeError
+}
+
+// errMissingField is wrapped in a DecodeError when a payload is valid JSON but lacks a field the gateway needs.
+func errMissingField(field string) error {
+	return errors.New("missing or invalid field " + field)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fasthttp/websocket"
+	"github.com/switchupcb/dasgo/dasgo"
+)
+
+func TestDispatchMalformedEvent(t *testing.T) {
+	handlers := new(Handlers)
+
+	On(handlers, func(*dasgo.MessageCreate) {
+		t.Error("handler was called for a malformed event")
+	})
+
+	payload := []byte(`{"t":"MESSAGE_CREATE","s":3,"op":0,"d":{"id":["not","an","id"]}}`)
+	err := handlers.dispatch(dasgo.FlagGatewayEventNameMessageCreate, payload)
+
+	var decodeError *DecodeError
+
+	if !errors.As(err, &decodeError) {
+		t.Fatalf("got %v, want a *DecodeError", err)
+	}
+	if decodeError.Event != dasgo.FlagGatewayEventNameMessageCreate || string(decodeError.Payload) != string(payload) {
+		t.Errorf("got event %q payload %q", decodeError.Event, decodeError.Payload)
+	}
+}
+
+func TestConnError(t *testing.T) {
+	tests := []struct {
+		err       error
+		code      int
+		reconnect bool
+	}{
+		{&websocket.CloseError{Code: 4000}, 4000, true},
+		{&websocket.CloseError{Code: 4004}, 4004, false},
+		{&websocket.CloseError{Code: websocket.CloseAbnormalClosure}, websocket.CloseAbnormalClosure, false},
+	}
+
+	for _, test := range tests {
+		var closeError *CloseError
+
+		if !errors.As(connError(test.err), &closeError) {
+			t.Fatalf("%v was not converted to a *CloseError", test.err)
+		}
+		if closeError.Code != test.code || closeError.Reconnect != test.reconnect {
+			t.Errorf("got code %d reconnect %t, want %d %t", closeError.Code, closeError.Reconnect, test.code, test.reconnect)
+		}
+		if !errors.Is(closeError, test.err) {
+			t.Errorf("%v does not wrap %v", closeError, test.err)
+		}
+	}
+
+	if err := errors.New("connection reset by peer"); connError(err) != err {
+		t.Error("errors without a close code should be returned unchanged")
+	}
+}
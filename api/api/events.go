@@ -0,0 +1,285 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/switchupcb/dasgo/dasgo"
+)
+
+// Event is satisfied by every dasgo gateway event type that handlers can be registered for.
+type Event interface {
+	dasgo.Hello |
+		dasgo.Ready |
+		dasgo.Resumed |
+		dasgo.Reconnect |
+		dasgo.InvalidSession |
+		dasgo.ApplicationCommandPermissionsUpdate |
+		dasgo.AutoModerationRuleCreate |
+		dasgo.AutoModerationRuleUpdate |
+		dasgo.AutoModerationRuleDelete |
+		dasgo.AutoModerationActionExecution |
+		dasgo.ChannelCreate |
+		dasgo.ChannelUpdate |
+		dasgo.ChannelDelete |
+		dasgo.ChannelPinsUpdate |
+		dasgo.ThreadCreate |
+		dasgo.ThreadUpdate |
+		dasgo.ThreadDelete |
+		dasgo.ThreadListSync |
+		dasgo.ThreadMemberUpdate |
+		dasgo.ThreadMembersUpdate |
+		dasgo.GuildCreate |
+		dasgo.GuildUpdate |
+		dasgo.GuildDelete |
+		dasgo.GuildBanAdd |
+		dasgo.GuildBanRemove |
+		dasgo.GuildEmojisUpdate |
+		dasgo.GuildStickersUpdate |
+		dasgo.GuildIntegrationsUpdate |
+		dasgo.GuildMemberAdd |
+		dasgo.GuildMemberRemove |
+		dasgo.GuildMemberUpdate |
+		dasgo.GuildMembersChunk |
+		dasgo.GuildRoleCreate |
+		dasgo.GuildRoleUpdate |
+		dasgo.GuildRoleDelete |
+		dasgo.GuildScheduledEventCreate |
+		dasgo.GuildScheduledEventUpdate |
+		dasgo.GuildScheduledEventDelete |
+		dasgo.GuildScheduledEventUserAdd |
+		dasgo.GuildScheduledEventUserRemove |
+		dasgo.IntegrationCreate |
+		dasgo.IntegrationUpdate |
+		dasgo.IntegrationDelete |
+		dasgo.InteractionCreate |
+		dasgo.InviteCreate |
+		dasgo.InviteDelete |
+		dasgo.MessageCreate |
+		dasgo.MessageUpdate |
+		dasgo.MessageDelete |
+		dasgo.MessageDeleteBulk |
+		dasgo.MessageReactionAdd |
+		dasgo.MessageReactionRemove |
+		dasgo.MessageReactionRemoveAll |
+		dasgo.MessageReactionRemoveEmoji |
+		dasgo.PresenceUpdate |
+		dasgo.StageInstanceCreate |
+		dasgo.StageInstanceDelete |
+		dasgo.StageInstanceUpdate |
+		dasgo.TypingStart |
+		dasgo.UserUpdate |
+		dasgo.VoiceStateUpdate |
+		dasgo.VoiceServerUpdate |
+		dasgo.WebhooksUpdate
+}
+
+// gatewayEvents maps each gateway event name to the dasgo type its data is decoded into.
+var gatewayEvents = map[string]reflect.Type{
+	dasgo.FlagGatewayEventNameHello:                               reflect.TypeOf(dasgo.Hello{}),
+	dasgo.FlagGatewayEventNameReady:                               reflect.TypeOf(dasgo.Ready{}),
+	dasgo.FlagGatewayEventNameResumed:                             reflect.TypeOf(dasgo.Resumed{}),
+	dasgo.FlagGatewayEventNameReconnect:                           reflect.TypeOf(dasgo.Reconnect{}),
+	dasgo.FlagGatewayEventNameInvalidSession:                      reflect.TypeOf(dasgo.InvalidSession{}),
+	dasgo.FlagGatewayEventNameApplicationCommandPermissionsUpdate: reflect.TypeOf(dasgo.ApplicationCommandPermissionsUpdate{}),
+	dasgo.FlagGatewayEventNameAutoModerationRuleCreate:            reflect.TypeOf(dasgo.AutoModerationRuleCreate{}),
+	dasgo.FlagGatewayEventNameAutoModerationRuleUpdate:            reflect.TypeOf(dasgo.AutoModerationRuleUpdate{}),
+	dasgo.FlagGatewayEventNameAutoModerationRuleDelete:            reflect.TypeOf(dasgo.AutoModerationRuleDelete{}),
+	dasgo.FlagGatewayEventNameAutoModerationActionExecution:       reflect.TypeOf(dasgo.AutoModerationActionExecution{}),
+	dasgo.FlagGatewayEventNameChannelCreate:                       reflect.TypeOf(dasgo.ChannelCreate{}),
+	dasgo.FlagGatewayEventNameChannelUpdate:                       reflect.TypeOf(dasgo.ChannelUpdate{}),
+	dasgo.FlagGatewayEventNameChannelDelete:                       reflect.TypeOf(dasgo.ChannelDelete{}),
+	dasgo.FlagGatewayEventNameChannelPinsUpdate:                   reflect.TypeOf(dasgo.ChannelPinsUpdate{}),
+	dasgo.FlagGatewayEventNameThreadCreate:                        reflect.TypeOf(dasgo.ThreadCreate{}),
+	dasgo.FlagGatewayEventNameThreadUpdate:                        reflect.TypeOf(dasgo.ThreadUpdate{}),
+	dasgo.FlagGatewayEventNameThreadDelete:                        reflect.TypeOf(dasgo.ThreadDelete{}),
+	dasgo.FlagGatewayEventNameThreadListSync:                      reflect.TypeOf(dasgo.ThreadListSync{}),
+	dasgo.FlagGatewayEventNameThreadMemberUpdate:                  reflect.TypeOf(dasgo.ThreadMemberUpdate{}),
+	dasgo.FlagGatewayEventNameThreadMembersUpdate:                 reflect.TypeOf(dasgo.ThreadMembersUpdate{}),
+	dasgo.FlagGatewayEventNameGuildCreate:                         reflect.TypeOf(dasgo.GuildCreate{}),
+	dasgo.FlagGatewayEventNameGuildUpdate:                         reflect.TypeOf(dasgo.GuildUpdate{}),
+	dasgo.FlagGatewayEventNameGuildDelete:                         reflect.TypeOf(dasgo.GuildDelete{}),
+	dasgo.FlagGatewayEventNameGuildBanAdd:                         reflect.TypeOf(dasgo.GuildBanAdd{}),
+	dasgo.FlagGatewayEventNameGuildBanRemove:                      reflect.TypeOf(dasgo.GuildBanRemove{}),
+	dasgo.FlagGatewayEventNameGuildEmojisUpdate:                   reflect.TypeOf(dasgo.GuildEmojisUpdate{}),
+	dasgo.FlagGatewayEventNameGuildStickersUpdate:                 reflect.TypeOf(dasgo.GuildStickersUpdate{}),
+	dasgo.FlagGatewayEventNameGuildIntegrationsUpdate:             reflect.TypeOf(dasgo.GuildIntegrationsUpdate{}),
+	dasgo.FlagGatewayEventNameGuildMemberAdd:                      reflect.TypeOf(dasgo.GuildMemberAdd{}),
+	dasgo.FlagGatewayEventNameGuildMemberRemove:                   reflect.TypeOf(dasgo.GuildMemberRemove{}),
+	dasgo.FlagGatewayEventNameGuildMemberUpdate:                   reflect.TypeOf(dasgo.GuildMemberUpdate{}),
+	dasgo.FlagGatewayEventNameGuildMembersChunk:                   reflect.TypeOf(dasgo.GuildMembersChunk{}),
+	dasgo.FlagGatewayEventNameGuildRoleCreate:                     reflect.TypeOf(dasgo.GuildRoleCreate{}),
+	dasgo.FlagGatewayEventNameGuildRoleUpdate:                     reflect.TypeOf(dasgo.GuildRoleUpdate{}),
+	dasgo.FlagGatewayEventNameGuildRoleDelete:                     reflect.TypeOf(dasgo.GuildRoleDelete{}),
+	dasgo.FlagGatewayEventNameGuildScheduledEventCreate:           reflect.TypeOf(dasgo.GuildScheduledEventCreate{}),
+	dasgo.FlagGatewayEventNameGuildScheduledEventUpdate:           reflect.TypeOf(dasgo.GuildScheduledEventUpdate{}),
+	dasgo.FlagGatewayEventNameGuildScheduledEventDelete:           reflect.TypeOf(dasgo.GuildScheduledEventDelete{}),
+	dasgo.FlagGatewayEventNameGuildScheduledEventUserAdd:          reflect.TypeOf(dasgo.GuildScheduledEventUserAdd{}),
+	dasgo.FlagGatewayEventNameGuildScheduledEventUserRemove:       reflect.TypeOf(dasgo.GuildScheduledEventUserRemove{}),
+	dasgo.FlagGatewayEventNameIntegrationCreate:                   reflect.TypeOf(dasgo.IntegrationCreate{}),
+	dasgo.FlagGatewayEventNameIntegrationUpdate:                   reflect.TypeOf(dasgo.IntegrationUpdate{}),
+	dasgo.FlagGatewayEventNameIntegrationDelete:                   reflect.TypeOf(dasgo.IntegrationDelete{}),
+	dasgo.FlagGatewayEventNameInteractionCreate:                   reflect.TypeOf(dasgo.InteractionCreate{}),
+	dasgo.FlagGatewayEventNameInviteCreate:                        reflect.TypeOf(dasgo.InviteCreate{}),
+	dasgo.FlagGatewayEventNameInviteDelete:                        reflect.TypeOf(dasgo.InviteDelete{}),
+	dasgo.FlagGatewayEventNameMessageCreate:                       reflect.TypeOf(dasgo.MessageCreate{}),
+	dasgo.FlagGatewayEventNameMessageUpdate:                       reflect.TypeOf(dasgo.MessageUpdate{}),
+	dasgo.FlagGatewayEventNameMessageDelete:                       reflect.TypeOf(dasgo.MessageDelete{}),
+	dasgo.FlagGatewayEventNameMessageDeleteBulk:                   reflect.TypeOf(dasgo.MessageDeleteBulk{}),
+	dasgo.FlagGatewayEventNameMessageReactionAdd:                  reflect.TypeOf(dasgo.MessageReactionAdd{}),
+	dasgo.FlagGatewayEventNameMessageReactionRemove:               reflect.TypeOf(dasgo.MessageReactionRemove{}),
+	dasgo.FlagGatewayEventNameMessageReactionRemoveAll:            reflect.TypeOf(dasgo.MessageReactionRemoveAll{}),
+	dasgo.FlagGatewayEventNameMessageReactionRemoveEmoji:          reflect.TypeOf(dasgo.MessageReactionRemoveEmoji{}),
+	dasgo.FlagGatewayEventNamePresenceUpdate:                      reflect.TypeOf(dasgo.PresenceUpdate{}),
+	dasgo.FlagGatewayEventNameStageInstanceCreate:                 reflect.TypeOf(dasgo.StageInstanceCreate{}),
+	dasgo.FlagGatewayEventNameStageInstanceDelete:                 reflect.TypeOf(dasgo.StageInstanceDelete{}),
+	dasgo.FlagGatewayEventNameStageInstanceUpdate:                 reflect.TypeOf(dasgo.StageInstanceUpdate{}),
+	dasgo.FlagGatewayEventNameTypingStart:                         reflect.TypeOf(dasgo.TypingStart{}),
+	dasgo.FlagGatewayEventNameUserUpdate:                          reflect.TypeOf(dasgo.UserUpdate{}),
+	dasgo.FlagGatewayEventNameVoiceStateUpdate:                    reflect.TypeOf(dasgo.VoiceStateUpdate{}),
+	dasgo.FlagGatewayEventNameVoiceServerUpdate:                   reflect.TypeOf(dasgo.VoiceServerUpdate{}),
+	dasgo.FlagGatewayEventNameWebhooksUpdate:                      reflect.TypeOf(dasgo.WebhooksUpdate{}),
+}
+
+// eventNames is the reverse of gatewayEvents.
+var eventNames = make(map[reflect.Type]string, len(gatewayEvents))
+
+var snowflakeType = reflect.TypeOf(dasgo.Snowflake(0))
+
+func init() {
+	for name, eventType := range gatewayEvents {
+		eventNames[eventType] = name
+	}
+}
+
+// eventName returns the gateway event name for the event type T.
+func eventName[T Event]() string {
+	return eventNames[reflect.TypeOf((*T)(nil)).Elem()]
+}
+
+// decodeEvent decodes a raw gateway payload into a pointer to the dasgo type registered for eventName.
+// Decode failures are returned as a *DecodeError.
+func decodeEvent(eventName string, payloadBytes []byte) (any, error) {
+	eventType, ok := gatewayEvents[eventName]
+
+	if !ok {
+		return nil, fmt.Errorf("no type registered for gateway event %s", eventName)
+	}
+
+	payload := new(dasgo.GatewayPayload)
+
+	if err := json.Unmarshal(payloadBytes, payload); err != nil {
+		return nil, &DecodeError{Event: eventName, Payload: payloadBytes, Err: err}
+	}
+
+	data := []byte(payload.Data)
+
+	if eventName == dasgo.FlagGatewayEventNameInvalidSession { // InvalidSession reads the "d" field itself.
+		data = payloadBytes
+	}
+
+	data, err := normalizeSnowflakes(data, eventType)
+
+	if err != nil {
+		return nil, &DecodeError{Event: eventName, Payload: payloadBytes, Err: err}
+	}
+
+	event := reflect.New(eventType).Interface()
+
+	if err = json.Unmarshal(data, event); err != nil {
+		return nil, &DecodeError{Event: eventName, Payload: payloadBytes, Err: err}
+	}
+
+	return event, nil
+}
+
+// normalizeSnowflakes rewrites the string IDs Discord sends into the plain numbers dasgo.Snowflake decodes from.
+// It walks the JSON alongside eventType, so only fields that are really snowflakes get rewritten.
+func normalizeSnowflakes(data []byte, eventType reflect.Type) ([]byte, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return data, nil
+	}
+
+	var value any
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(normalizeValue(value, eventType))
+}
+
+func normalizeValue(value any, valueType reflect.Type) any {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+
+	if valueType == snowflakeType {
+		if id, ok := value.(string); ok {
+			if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+				return json.Number(id)
+			}
+		}
+
+		return value
+	}
+
+	switch valueType.Kind() {
+	case reflect.Array, reflect.Slice:
+		if values, ok := value.([]any); ok {
+			for i := range values {
+				values[i] = normalizeValue(values[i], valueType.Elem())
+			}
+		}
+	case reflect.Map:
+		if values, ok := value.(map[string]any); ok {
+			for key := range values {
+				values[key] = normalizeValue(values[key], valueType.Elem())
+			}
+		}
+	case reflect.Struct:
+		if values, ok := value.(map[string]any); ok {
+			normalizeFields(values, valueType)
+		}
+	}
+
+	return value
+}
+
+func normalizeFields(values map[string]any, structType reflect.Type) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if field.Anonymous && name == "" { // Fields of embedded structs are promoted into the parent object.
+			embeddedType := field.Type
+
+			for embeddedType.Kind() == reflect.Pointer {
+				embeddedType = embeddedType.Elem()
+			}
+
+			if embeddedType.Kind() == reflect.Struct {
+				normalizeFields(values, embeddedType)
+				continue
+			}
+		}
+
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if value, ok := values[name]; ok {
+			values[name] = normalizeValue(value, field.Type)
+		}
+	}
+}
@@ -2,73 +2,121 @@ package api
 
 import (
 	"errors"
+	"reflect"
 	"sync"
 
-	"github.com/goccy/go-json"
 	"github.com/switchupcb/dasgo/dasgo"
 )
 
 // Handlers contains handlers for gateway events.
 type Handlers struct {
-	OnHello         []func(*dasgo.Hello)
-	OnMessageCreate []func(*dasgo.Message)
-	OnReady         []func(*dasgo.Ready)
-	OnReconnect     []func(*dasgo.Reconnect)
-	mutex           sync.Mutex // Used to prevents concurrent writes to the handlers.
+	handlers map[string][]*Handler // Handlers registered for each gateway event name.
+	mutex    sync.Mutex            // Used to prevents concurrent writes to the handlers.
 }
 
+// Handler is a registered event handler, returned so that it can be removed later.
+type Handler struct {
+	call     func(event any)
+	event    string
+	handlers *Handlers
+}
+
+// On registers a handler for the gateway event T, for example:
+//
+//	api.On(handlers, func(event *dasgo.MessageCreate) { ... })
+func On[T Event](handlers *Handlers, function func(*T)) *Handler {
+	return handlers.add(eventName[T](), func(event any) {
+		function(event.(*T))
+	})
+}
+
+// Add registers a handler for the gateway event with the given name. The function must take a
+// pointer to the event's dasgo type, except for MESSAGE_CREATE, which also accepts func(*dasgo.Message).
 func (handlers *Handlers) Add(event string, function any) error {
+	eventType, ok := gatewayEvents[event]
+
+	if !ok {
+		return errors.New("failed to match event to gateway event")
+	}
+
+	if function, ok := function.(func(*dasgo.Message)); ok && event == dasgo.FlagGatewayEventNameMessageCreate {
+		On(handlers, func(event *dasgo.MessageCreate) {
+			function(event.Message)
+		})
+
+		return nil
+	}
+
+	functionValue := reflect.ValueOf(function)
+	functionType := functionValue.Type()
+
+	if functionType.Kind() != reflect.Func || functionType.NumIn() != 1 || functionType.NumOut() != 0 || functionType.In(0) != reflect.New(eventType).Type() {
+		return errors.New("function signature was not correct for the specified event")
+	}
+
+	handlers.add(event, func(event any) {
+		functionValue.Call([]reflect.Value{reflect.ValueOf(event)})
+	})
+
+	return nil
+}
+
+// Remove unregisters the handler. Events that are already being dispatched may still reach it.
+func (handler *Handler) Remove() {
+	handlers := handler.handlers
+
 	handlers.mutex.Lock()
 	defer handlers.mutex.Unlock()
 
-	failed := false
+	registered := handlers.handlers[handler.event]
 
-	switch event {
-	case dasgo.FlagGatewayEventNameHello:
-		if function, ok := function.(func(*dasgo.Hello)); ok {
-			handlers.OnHello = append(handlers.OnHello, function)
-		} else {
-			failed = true
-		}
-	case dasgo.FlagGatewayEventNameMessageCreate:
-		if function, ok := function.(func(*dasgo.Message)); ok {
-			handlers.OnMessageCreate = append(handlers.OnMessageCreate, function)
-		} else {
-			failed = true
-		}
-	case dasgo.FlagGatewayEventNameReady:
-		if function, ok := function.(func(*dasgo.Ready)); ok {
-			handlers.OnReady = append(handlers.OnReady, function)
-		} else {
-			failed = true
+	for i, other := range registered {
+		if other == handler {
+			handlers.handlers[handler.event] = append(registered[:i:i], registered[i+1:]...)
+			break
 		}
-	case dasgo.FlagGatewayEventNameReconnect:
-		if function, ok := function.(func(*dasgo.Reconnect)); ok {
-			handlers.OnReconnect = append(handlers.OnReconnect, function)
-		} else {
-			failed = true
-		}
-	default:
-		return errors.New("failed to match event to gateway event")
 	}
+}
 
-	if failed {
-		return errors.New("function signature was not correct for the specified event")
+func (handlers *Handlers) add(event string, call func(event any)) *Handler {
+	handlers.mutex.Lock()
+	defer handlers.mutex.Unlock()
+
+	if handlers.handlers == nil {
+		handlers.handlers = make(map[string][]*Handler)
 	}
 
-	return nil
+	handler := &Handler{
+		call:     call,
+		event:    event,
+		handlers: handlers,
+	}
+
+	handlers.handlers[event] = append(handlers.handlers[event], handler)
+
+	return handler
 }
 
-func createGatewayEvent(genericMap []byte, container any) error {
-	payload := new(dasgo.GatewayPayload)
+// dispatch decodes a raw gateway payload as the named event, and calls every handler registered for it.
+// The payload is only decoded if at least one handler is registered.
+func (handlers *Handlers) dispatch(event string, payloadBytes []byte) error {
+	handlers.mutex.Lock()
+	registered := append([]*Handler(nil), handlers.handlers[event]...)
+	handlers.mutex.Unlock()
 
-	if err := json.Unmarshal(genericMap, payload); err != nil {
-		return err
+	if len(registered) == 0 {
+		return nil
 	}
 
-	if err := json.Unmarshal(payload.Data, container); err != nil {
+	decoded, err := decodeEvent(event, payloadBytes)
+
+	if err != nil {
 		return err
 	}
 
+	for _, handler := range registered {
+		go handler.call(decoded)
+	}
+
 	return nil
 }
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/switchupcb/dasgo/dasgo"
+)
+
+func readPayload(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// receive waits for a handler to deliver an event, since handlers run on their own goroutines.
+func receive[T any](t *testing.T, events <-chan *T) *T {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called")
+		return nil
+	}
+}
+
+func TestOnMessageCreateRecorded(t *testing.T) {
+	handlers := new(Handlers)
+	events := make(chan *dasgo.MessageCreate, 1)
+
+	On(handlers, func(event *dasgo.MessageCreate) {
+		events <- event
+	})
+
+	if err := handlers.dispatch(dasgo.FlagGatewayEventNameMessageCreate, readPayload(t, "../message.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	event := receive(t, events)
+
+	if event.ID != 1132993012746682378 || event.ChannelID != 1074048036780646570 {
+		t.Errorf("got id=%d channel_id=%d", event.ID, event.ChannelID)
+	}
+	if event.Author == nil || event.Author.ID != 1072069875993956372 || event.Author.Username != "skifli" {
+		t.Errorf("got author %+v", event.Author)
+	}
+	if event.GuildID == nil || *event.GuildID != 1072195756557078528 {
+		t.Errorf("got guild_id %v", event.GuildID)
+	}
+	if event.ReferencedMessage == nil || *event.ReferencedMessage == nil || (*event.ReferencedMessage).ID != 1110292858793107587 {
+		t.Errorf("referenced message was not decoded: %+v", event.ReferencedMessage)
+	}
+}
+
+func TestOnRecordedPayloads(t *testing.T) {
+	tests := []struct {
+		file  string
+		event string
+		check func(t *testing.T, handlers *Handlers) func()
+	}{
+		{"hello.json", dasgo.FlagGatewayEventNameHello, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.Hello, 1)
+			On(handlers, func(event *dasgo.Hello) { events <- event })
+
+			return func() {
+				if event := receive(t, events); event.HeartbeatInterval != 41250 {
+					t.Errorf("got heartbeat interval %d", event.HeartbeatInterval)
+				}
+			}
+		}},
+		{"ready.json", dasgo.FlagGatewayEventNameReady, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.Ready, 1)
+			On(handlers, func(event *dasgo.Ready) { events <- event })
+
+			return func() {
+				event := receive(t, events)
+
+				if event.SessionID != "d41c8c2a3f9e6f0a7b5e2c1d9a8b7c6e" || event.User == nil || event.User.ID != 1072069875993956372 {
+					t.Errorf("got session %q user %+v", event.SessionID, event.User)
+				}
+				if len(event.Guilds) != 1 || event.Guilds[0].ID != 1072195756557078528 {
+					t.Errorf("got guilds %+v", event.Guilds)
+				}
+			}
+		}},
+		{"resumed.json", dasgo.FlagGatewayEventNameResumed, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.Resumed, 1)
+			On(handlers, func(event *dasgo.Resumed) { events <- event })
+
+			return func() { receive(t, events) }
+		}},
+		{"reconnect.json", dasgo.FlagGatewayEventNameReconnect, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.Reconnect, 1)
+			On(handlers, func(event *dasgo.Reconnect) { events <- event })
+
+			return func() { receive(t, events) }
+		}},
+		{"invalid_session.json", dasgo.FlagGatewayEventNameInvalidSession, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.InvalidSession, 1)
+			On(handlers, func(event *dasgo.InvalidSession) { events <- event })
+
+			return func() {
+				if event := receive(t, events); !event.Data {
+					t.Error("expected the session to be resumable")
+				}
+			}
+		}},
+		{"message_reaction_add.json", dasgo.FlagGatewayEventNameMessageReactionAdd, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.MessageReactionAdd, 1)
+			On(handlers, func(event *dasgo.MessageReactionAdd) { events <- event })
+
+			return func() {
+				event := receive(t, events)
+
+				if event.MessageID != 1132993012746682378 || event.Emoji == nil || event.Emoji.Name == nil || *event.Emoji.Name != "🔥" {
+					t.Errorf("got message %d emoji %+v", event.MessageID, event.Emoji)
+				}
+			}
+		}},
+		{"message_delete_bulk.json", dasgo.FlagGatewayEventNameMessageDeleteBulk, func(t *testing.T, handlers *Handlers) func() {
+			events := make(chan *dasgo.MessageDeleteBulk, 1)
+			On(handlers, func(event *dasgo.MessageDeleteBulk) { events <- event })
+
+			return func() {
+				event := receive(t, events)
+
+				if len(event.MessageIDs) != 2 || event.MessageIDs[1] != 1132993040755982336 {
+					t.Errorf("got ids %v", event.MessageIDs)
+				}
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.event, func(t *testing.T) {
+			handlers := new(Handlers)
+			wait := test.check(t, handlers)
+
+			if err := handlers.dispatch(test.event, readPayload(t, "testdata/"+test.file)); err != nil {
+				t.Fatal(err)
+			}
+
+			wait()
+		})
+	}
+}
+
+func TestHandlerRemove(t *testing.T) {
+	handlers := new(Handlers)
+	kept := make(chan *dasgo.MessageCreate, 2)
+	removed := make(chan *dasgo.MessageCreate, 2)
+
+	On(handlers, func(event *dasgo.MessageCreate) { kept <- event })
+	handler := On(handlers, func(event *dasgo.MessageCreate) { removed <- event })
+
+	handler.Remove()
+	handler.Remove() // Removing twice is harmless.
+
+	if err := handlers.dispatch(dasgo.FlagGatewayEventNameMessageCreate, readPayload(t, "../message.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	receive(t, kept)
+
+	select {
+	case <-removed:
+		t.Fatal("removed handler was called")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAddCompatibility(t *testing.T) {
+	handlers := new(Handlers)
+	messages := make(chan *dasgo.Message, 1)
+
+	if err := handlers.Add(dasgo.FlagGatewayEventNameMessageCreate, func(message *dasgo.Message) { messages <- message }); err != nil {
+		t.Fatal(err)
+	}
+	if err := handlers.Add(dasgo.FlagGatewayEventNameTypingStart, func(*dasgo.TypingStart) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handlers.Add(dasgo.FlagGatewayEventNameReady, func(*dasgo.Message) {}); err == nil {
+		t.Error("expected an error for a handler of the wrong type")
+	}
+	if err := handlers.Add("NOT_AN_EVENT", func(*dasgo.Message) {}); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+
+	if err := handlers.dispatch(dasgo.FlagGatewayEventNameMessageCreate, readPayload(t, "../message.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if message := receive(t, messages); message.ID != 1132993012746682378 {
+		t.Errorf("got id %d", message.ID)
+	}
+}
+
+func TestEveryEventDecodes(t *testing.T) {
+	if len(gatewayEvents) != len(eventNames) {
+		t.Fatalf("%d event names share %d types", len(gatewayEvents), len(eventNames))
+	}
+
+	for name := range gatewayEvents {
+		payload := `{"op":0,"d":{}}`
+
+		if name == dasgo.FlagGatewayEventNameInvalidSession {
+			payload = `{"op":9,"d":false}`
+		}
+
+		if _, err := decodeEvent(name, []byte(payload)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if name := eventName[dasgo.GuildMemberAdd](); name != dasgo.FlagGatewayEventNameGuildMemberAdd {
+		t.Errorf("got %q for GuildMemberAdd", name)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/switchupcb/dasgo/dasgo"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	restBaseURL   = "https://discord.com/api/v" + API_VERSION + "/"
+	gatewayURLTTL = time.Hour // How long a /gateway lookup is reused for, since the URL rarely changes.
+)
+
+// REST makes requests to Discord's REST API.
+type REST struct {
+	BaseURL           string           // BaseURL is the root that request paths are relative to, ending in a slash.
+	Client            *fasthttp.Client // Client sends the requests.
+	Token             string           // Token authorizes requests, if it is set.
+	gatewayURL        string           // gatewayURL is the cached result of the last /gateway lookup.
+	gatewayURLExpires time.Time        // gatewayURLExpires is when gatewayURL has to be looked up again.
+	mutex             sync.Mutex       // Guards the cached gateway URL.
+}
+
+// NewREST creates a REST client for Discord's API, authorized with token if it isn't empty.
+func NewREST(token string) *REST {
+	return &REST{
+		BaseURL: restBaseURL,
+		Client:  &requestClient,
+		Token:   token,
+	}
+}
+
+// Do sends a request to path, with body encoded as JSON if it isn't nil, and decodes the JSON response into result if
+// it isn't nil. Responses with a status outside 2xx are returned as a *RESTError.
+func (rest *REST) Do(method string, path string, body any, result any) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(method)
+	req.SetRequestURI(rest.BaseURL + path)
+	req.Header.Set("User-Agent", USER_AGENT)
+
+	if rest.Token != "" {
+		req.Header.Set("Authorization", rest.Token)
+	}
+
+	if body != nil {
+		data, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		req.Header.SetContentType("application/json")
+		req.SetBody(data)
+	}
+
+	if err := rest.Client.Do(req, resp); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if status := resp.StatusCode(); status < 200 || status > 299 {
+		restError := &RESTError{Method: method, Path: path, StatusCode: status}
+		json.Unmarshal(resp.Body(), restError) // Discord describes most errors in the body, but not all of them.
+
+		return restError
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(resp.Body(), result); err != nil {
+		return fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// GatewayURL returns the gateway's WebSocket URL from GET /gateway. Lookups are cached for an hour.
+func (rest *REST) GatewayURL() (string, error) {
+	rest.mutex.Lock()
+	defer rest.mutex.Unlock()
+
+	if rest.gatewayURL != "" && time.Now().Before(rest.gatewayURLExpires) {
+		return rest.gatewayURL, nil
+	}
+
+	response := new(dasgo.GetGatewayResponse)
+
+	if err := rest.Do(fasthttp.MethodGet, dasgo.EndpointGetGateway, nil, response); err != nil {
+		return "", err
+	} else if response.URL == "" {
+		return "", errors.New("GET /gateway returned no URL")
+	}
+
+	rest.gatewayURL = response.URL
+	rest.gatewayURLExpires = time.Now().Add(gatewayURLTTL)
+
+	return rest.gatewayURL, nil
+}
+
+// InvalidateGatewayURL drops the cached gateway URL, so the next call to GatewayURL looks it up again.
+func (rest *REST) InvalidateGatewayURL() {
+	rest.mutex.Lock()
+	defer rest.mutex.Unlock()
+
+	rest.gatewayURL = ""
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/switchupcb/dasgo/dasgo"
+)
+
+// newRESTStandIn serves handler as the REST API, and returns a REST client pointed at it.
+func newRESTStandIn(t *testing.T, handler http.HandlerFunc) *REST {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	rest := NewREST("token")
+	rest.BaseURL = server.URL + "/api/v" + API_VERSION + "/"
+
+	return rest
+}
+
+func TestGatewayURLCached(t *testing.T) {
+	var lookups atomic.Int32
+
+	rest := newRESTStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v"+API_VERSION+"/gateway" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		lookups.Add(1)
+		io.WriteString(w, `{"url":"wss://gateway.example"}`)
+	})
+
+	for i := 0; i < 3; i++ {
+		url, err := rest.GatewayURL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url != "wss://gateway.example" {
+			t.Fatalf("got %q", url)
+		}
+	}
+
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("looked up the gateway %d times, want 1", n)
+	}
+
+	rest.gatewayURLExpires = time.Now() // Let the cached URL expire.
+
+	if _, err := rest.GatewayURL(); err != nil {
+		t.Fatal(err)
+	}
+
+	rest.InvalidateGatewayURL()
+
+	if _, err := rest.GatewayURL(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := lookups.Load(); n != 3 {
+		t.Errorf("looked up the gateway %d times, want 3", n)
+	}
+}
+
+func TestGatewayURLErrors(t *testing.T) {
+	var fail atomic.Bool
+
+	fail.Store(true)
+
+	rest := newRESTStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"message":"upstream connect error","code":0}`)
+			return
+		}
+
+		io.WriteString(w, `{}`)
+	})
+
+	_, err := rest.GatewayURL()
+
+	var restError *RESTError
+
+	if !errors.As(err, &restError) {
+		t.Fatalf("got %v, want a *RESTError", err)
+	}
+	if restError.StatusCode != http.StatusServiceUnavailable || restError.Message != "upstream connect error" || restError.Path != dasgo.EndpointGetGateway {
+		t.Errorf("got %+v", restError)
+	}
+
+	fail.Store(false)
+
+	if url, err := rest.GatewayURL(); err == nil {
+		t.Errorf("got %q for a response without a URL", url)
+	}
+	if rest.gatewayURL != "" {
+		t.Errorf("cached %q after a failed lookup", rest.gatewayURL)
+	}
+}
+
+func TestRESTDo(t *testing.T) {
+	rest := newRESTStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "token" {
+			t.Errorf("got Authorization %q", auth)
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("got Content-Type %q", contentType)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+
+	var result struct {
+		Content string `json:"content"`
+	}
+
+	if err := rest.Do(http.MethodPost, "channels/1/messages", map[string]string{"content": "hi"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != "hi" {
+		t.Errorf("got %q", result.Content)
+	}
+}
+
+func TestRESTDoConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	rest := NewREST("")
+	rest.BaseURL = server.URL + "/"
+
+	var restError *RESTError
+
+	if err := rest.Do(http.MethodGet, dasgo.EndpointGetGateway, nil, nil); err == nil || errors.As(err, &restError) {
+		t.Errorf("got %v, want a connection error", err)
+	}
+}
+
+func TestConnectDiscoversGatewayURL(t *testing.T) {
+	fake := newFakeGateway(t)
+	defer fake.server.Close()
+
+	gateway := CreateGateway(new(SelfBot))
+	gateway.REST = newRESTStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"url":"`+fake.url+`"}`)
+	})
+
+	ready := make(chan struct{}, 1)
+	On(gateway.Handlers, func(*dasgo.Ready) { ready <- struct{}{} })
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- gateway.Connect(context.Background())
+	}()
+
+	waitFor(t, ready, "READY")
+
+	gateway.Close()
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectRefreshesGatewayURLAfter404(t *testing.T) {
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	lookups := make(chan struct{}, 8)
+
+	gateway := CreateGateway(new(SelfBot))
+	gateway.REST = newRESTStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		lookups <- struct{}{}
+		io.WriteString(w, `{"url":"ws`+missing.URL[len("http"):]+`"}`)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- gateway.Connect(ctx)
+	}()
+
+	waitFor(t, lookups, "the first lookup")
+	waitFor(t, lookups, "a lookup after the 404")
+
+	cancel()
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
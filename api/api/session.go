@@ -1,103 +1,121 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/fasthttp/websocket"
 	"github.com/goccy/go-json"
 	"github.com/mitchellh/mapstructure"
 	"github.com/switchupcb/dasgo/dasgo"
-	"github.com/valyala/fasthttp"
+)
+
+const (
+	closeTimeout      = 5 * time.Second // How long Close waits for Discord to answer the close frame.
+	maxReconnectDelay = time.Minute     // The longest wait between failed connection attempts.
 )
 
 var (
-	gatewayURL        = "wss://gateway.discord.gg/"
+	errReconnectRequested = errors.New("gateway requested a reconnect")
+
+	defaultGatewayURL = "wss://gateway.discord.gg/" // Used when the gateway URL can't be looked up.
 	gatewayParameters = "?encoding=json&v=" + API_VERSION
 	headers           = make(http.Header)
 )
 
 // Gateway represents a Discord WebSocket connection.
 type Gateway struct {
-	CloseChan         chan struct{}   // CloseChan is used as a signal to stop for the gateway's goroutines.
-	Conn              *websocket.Conn // Conn represents a connection to the Discord WebSocket.
-	GatewayURL        string          // GatewayURL contains the URL used when resuming after a disconnect.
-	Handlers          *Handlers       // Handles for gateway events
-	HeartbeatInterval time.Duration   // The interval the client should wait between sending heartbeats.
-	LastSeq           float64         // LastSeq contains the last sequence number received by the client.
-	SelfBot           *SelfBot        // SelfBot contains data relating to the self-bot.
-	SessionID         string          // SessionID contains the ID of the gateway.
+	Conn              *websocket.Conn    // Conn represents a connection to the Discord WebSocket.
+	GatewayURL        string             // GatewayURL contains the URL used when resuming after a disconnect.
+	Handlers          *Handlers          // Handles for gateway events
+	HeartbeatInterval time.Duration      // The interval the client should wait between sending heartbeats.
+	LastSeq           float64            // LastSeq contains the last sequence number received by the client.
+	SelfBot           *SelfBot           // SelfBot contains data relating to the self-bot.
+	SessionID         string             // SessionID contains the ID of the gateway.
+	Compress          bool               // Compress enables zlib-stream transport compression.
+	inflater          *zlibStream        // inflater decompresses the current connection's zlib-stream.
+	OnError           func(err error)    // OnError is called with errors that happen in the background, e.g. while reading events.
+	REST              *REST              // REST is used to look up the gateway URL.
+	cancel            context.CancelFunc // cancel stops the running Connect call.
+	done              chan struct{}      // done is closed when the running Connect call has returned.
+	mutex             sync.Mutex         // Guards LastSeq, cancel and done, which are shared with the heartbeat and Close.
+	writeMutex        sync.Mutex         // Serialises writes, since the heartbeat and the event loop both send payloads.
 }
 
 func CreateGateway(selfBot *SelfBot) *Gateway {
 	return &Gateway{
-		CloseChan: make(chan struct{}),
-		Handlers:  new(Handlers),
-		SelfBot:   selfBot,
+		Handlers: new(Handlers),
+		REST:     NewREST(selfBot.Token),
+		SelfBot:  selfBot,
 	}
 }
 
-func getGatewayURL() error {
-	req := fasthttp.AcquireRequest()
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseRequest(req)
-	defer fasthttp.ReleaseResponse(resp)
+func (gateway *Gateway) canReconnect() bool {
+	return gateway.SessionID != "" && gateway.GatewayURL != "" && gateway.lastSeq() != 0
+}
 
-	req.Header.SetMethod(fasthttp.MethodGet)
-	req.SetRequestURI("https://discord.com/api/v" + API_VERSION + "/gateway")
+func (gateway *Gateway) lastSeq() float64 {
+	gateway.mutex.Lock()
+	defer gateway.mutex.Unlock()
 
-	if err := requestClient.Do(req, resp); err != nil {
-		return err
-	}
+	return gateway.LastSeq
+}
 
-	jsonMap := make(map[string]string)
+func (gateway *Gateway) setLastSeq(seq float64) {
+	gateway.mutex.Lock()
+	defer gateway.mutex.Unlock()
 
-	if err := json.Unmarshal(req.Body(), &jsonMap); err != nil {
-		return err
+	gateway.LastSeq = seq
+}
+
+// reportError passes an error that can't be returned to a caller, e.g. one from the event loop, to OnError.
+func (gateway *Gateway) reportError(err error) {
+	if gateway.OnError != nil {
+		gateway.OnError(err)
 	}
+}
 
-	gatewayURL = jsonMap["url"]
+// connError converts an error from the WebSocket connection into a CloseError when it carries a close code.
+func connError(err error) error {
+	var closeError *websocket.CloseError
 
-	return nil
-}
+	if errors.As(err, &closeError) {
+		return newCloseError(closeError)
+	}
 
-func (gateway *Gateway) canReconnect() bool {
-	return gateway.SessionID != "" && gateway.GatewayURL != "" && gateway.LastSeq != 0
+	return err
 }
 
 func (gateway *Gateway) readMessage() ([]byte, genericMap, error) {
-	_, message, err := gateway.Conn.ReadMessage()
+	messageType, message, err := gateway.Conn.ReadMessage()
 
 	if err != nil {
-		closeError := err.(*websocket.CloseError)
+		return nil, nil, connError(err)
+	}
 
-		switch closeError.Code {
-		case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived: // Websocket closed without any close code.
-			go gateway.reset()
+	if gateway.inflater != nil && messageType == websocket.BinaryMessage {
+		if message, err = gateway.inflater.Feed(message); err != nil {
 			return nil, nil, err
-		default:
-			if closeEvent, ok := dasgo.GatewayCloseEventCodes[closeError.Code]; ok {
-				if closeEvent.Reconnect { // If the session is reconnectable.
-					go gateway.reconnect()
-				}
-
-				return nil, nil, fmt.Errorf("gateway closed with code %d: %s - %s", closeEvent.Code, closeEvent.Description, closeEvent.Explanation)
-			} else {
-				return nil, nil, err
-			}
+		} else if message == nil { // The payload continues in the next frame.
+			return gateway.readMessage()
 		}
 	}
 
 	payload := make(genericMap)
 
 	if err = json.Unmarshal(message, &payload); err != nil {
-		return nil, nil, err
+		return nil, nil, &DecodeError{Payload: message, Err: err}
 	}
 
 	return message, payload, nil
 }
 
+// sendMessage writes a payload to the connection. Failed writes don't end the session, since the read loop sees the same failure.
 func (gateway *Gateway) sendMessage(jsonPayload genericMap, reconnect bool) error {
 	payload, err := json.Marshal(jsonPayload)
 
@@ -105,26 +123,11 @@ func (gateway *Gateway) sendMessage(jsonPayload genericMap, reconnect bool) erro
 		return err
 	}
 
-	err = gateway.Conn.WriteMessage(websocket.TextMessage, payload)
-
-	if err != nil {
-		closeError := err.(*websocket.CloseError)
-
-		switch closeError.Code {
-		case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived: // Websocket closed without any close code.
-			go gateway.reset()
-			return err
-		default:
-			if closeEvent, ok := dasgo.GatewayCloseEventCodes[closeError.Code]; ok {
-				if closeEvent.Reconnect { // If the session is reconnectable.
-					go gateway.reconnect()
-				}
+	gateway.writeMutex.Lock()
+	defer gateway.writeMutex.Unlock()
 
-				return fmt.Errorf("gateway closed with code %d: %s - %s", closeEvent.Code, closeEvent.Description, closeEvent.Explanation)
-			} else {
-				return err
-			}
-		}
+	if err = gateway.Conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+		return connError(err)
 	}
 
 	return nil
@@ -133,10 +136,10 @@ func (gateway *Gateway) sendMessage(jsonPayload genericMap, reconnect bool) erro
 func (gateway *Gateway) sendHeartbeat() error {
 	var err error
 
-	if gateway.LastSeq == 0 {
+	if seq := gateway.lastSeq(); seq == 0 {
 		err = gateway.sendMessage(genericMap{"op": dasgo.FlagGatewayOpcodeHeartbeat, "d": nil}, false)
 	} else {
-		err = gateway.sendMessage(genericMap{"op": dasgo.FlagGatewayOpcodeHeartbeat, "d": gateway.LastSeq}, false)
+		err = gateway.sendMessage(genericMap{"op": dasgo.FlagGatewayOpcodeHeartbeat, "d": seq}, false)
 	}
 
 	if err != nil {
@@ -146,7 +149,7 @@ func (gateway *Gateway) sendHeartbeat() error {
 	return nil
 }
 
-func (gateway *Gateway) startHeartbeatSender() {
+func (gateway *Gateway) startHeartbeatSender(ctx context.Context) {
 	ticker := time.NewTicker(gateway.HeartbeatInterval * time.Millisecond)
 	defer ticker.Stop()
 
@@ -156,10 +159,8 @@ func (gateway *Gateway) startHeartbeatSender() {
 			if err := gateway.sendHeartbeat(); err != nil {
 				return
 			}
-		case <-gateway.CloseChan:
+		case <-ctx.Done():
 			return
-		default:
-			time.Sleep(25 * time.Millisecond)
 		}
 	}
 }
@@ -171,22 +172,21 @@ func (gateway *Gateway) gatewayHello() error {
 		return err
 	}
 
-	if payload["op"].(float64) != dasgo.FlagGatewayOpcodeHello {
-		return fmt.Errorf("unexpected opcode when parsing hello event (expected %d, got %f)", dasgo.FlagGatewayOpcodeHello, payload["op"].(float64))
+	if opcode, _ := payload["op"].(float64); opcode != dasgo.FlagGatewayOpcodeHello {
+		return fmt.Errorf("unexpected opcode when parsing hello event (expected %d, got %f)", dasgo.FlagGatewayOpcodeHello, opcode)
 	}
 
-	gateway.HeartbeatInterval = time.Duration(payload["d"].(genericMap)["heartbeat_interval"].(float64))
-
-	go gateway.startHeartbeatSender()
-
-	helloEvent := new(dasgo.Hello)
+	data, _ := payload["d"].(genericMap)
+	heartbeatInterval, ok := data["heartbeat_interval"].(float64)
 
-	if err = createGatewayEvent(payloadBytes, helloEvent); err != nil {
-		return err
+	if !ok {
+		return &DecodeError{Event: dasgo.FlagGatewayEventNameHello, Payload: payloadBytes, Err: errMissingField("heartbeat_interval")}
 	}
 
-	for _, handler := range gateway.Handlers.OnHello {
-		go handler(helloEvent)
+	gateway.HeartbeatInterval = time.Duration(heartbeatInterval)
+
+	if err = gateway.Handlers.dispatch(dasgo.FlagGatewayEventNameHello, payloadBytes); err != nil {
+		gateway.reportError(err)
 	}
 
 	return nil
@@ -201,7 +201,7 @@ func (gateway *Gateway) gatewayIdentify() error {
 			"d": genericMap{
 				"token":      gateway.SelfBot.Token,
 				"session_id": gateway.SessionID,
-				"seq":        int(gateway.LastSeq)},
+				"seq":        int(gateway.lastSeq())},
 		}, false)
 
 		if err != nil {
@@ -265,47 +265,60 @@ func (gateway *Gateway) gatewayIdentify() error {
 	return nil
 }
 
-func (gateway *Gateway) startMessageHandler() {
+// startMessageHandler runs the event loop until the session ends, and returns the reason it ended.
+func (gateway *Gateway) startMessageHandler() error {
 	for {
 		messageBytes, message, err := gateway.readMessage()
 
-		if err != nil { // Error occurred, assume readMessage handled it.
-			return
+		if err != nil {
+			var decodeError *DecodeError
+
+			if errors.As(err, &decodeError) { // Skip the malformed payload, the connection itself is fine.
+				gateway.reportError(err)
+				continue
+			}
+
+			return err
 		}
 
-		op := message["op"].(float64)
+		op, ok := message["op"].(float64)
+
+		if !ok {
+			gateway.reportError(&DecodeError{Payload: messageBytes, Err: errMissingField("op")})
+			continue
+		}
 
 		switch op {
 		case dasgo.FlagGatewayOpcodeDispatch: // Dispatch event.
-			eventName := message["t"].(string)
-
-			switch eventName {
-			case string(dasgo.FlagGatewayEventNameMessageCreate):
-				messageEvent := new(dasgo.Message)
+			eventName, _ := message["t"].(string)
 
-				check(createGatewayEvent(messageBytes, messageEvent))
-
-				for _, handler := range gateway.Handlers.OnMessageCreate {
-					go handler(messageEvent)
+			if _, ok := gatewayEvents[eventName]; ok { // Skip events dasgo has no type for, e.g. user-only events.
+				if err = gateway.Handlers.dispatch(eventName, messageBytes); err != nil {
+					gateway.reportError(err)
 				}
 			}
 		case dasgo.FlagGatewayOpcodeHeartbeat: // Discord is asking for a hearbeat.
 			gateway.sendHeartbeat()
 		case dasgo.FlagGatewayOpcodeHeartbeatACK: // Discord is acknowledging that we sent a heartbeat.
 			continue
-		case dasgo.FlagGatewayOpcodeReconnect:
-			recconectEvent := new(dasgo.Reconnect)
+		case dasgo.FlagGatewayOpcodeInvalidSession:
+			resumable, _ := message["d"].(bool)
 
-			for _, handler := range gateway.Handlers.OnReconnect {
-				go handler(recconectEvent)
+			if err = gateway.Handlers.dispatch(dasgo.FlagGatewayEventNameInvalidSession, messageBytes); err != nil {
+				gateway.reportError(err)
 			}
 
-			gateway.reconnect()
-			return
+			return &InvalidSessionError{Resumable: resumable}
+		case dasgo.FlagGatewayOpcodeReconnect:
+			if err = gateway.Handlers.dispatch(dasgo.FlagGatewayEventNameReconnect, messageBytes); err != nil {
+				gateway.reportError(err)
+			}
+
+			return errReconnectRequested
 		}
 
-		if message["s"] != nil { // Some payloads, for example the heartbeat ack, don't contribute to the sequence ID.
-			gateway.LastSeq = message["s"].(float64)
+		if seq, ok := message["s"].(float64); ok { // Some payloads, for example the heartbeat ack, don't contribute to the sequence ID.
+			gateway.setLastSeq(seq)
 		}
 	}
 }
@@ -317,48 +330,111 @@ func (gateway *Gateway) gatewayReady() error {
 		return err
 	}
 
-	opcode := payload["op"].(float64)
+	opcode, _ := payload["op"].(float64)
 
 	if opcode == dasgo.FlagGatewayOpcodeInvalidSession { // Invalid session. Re-try the connection.
-		<-gateway.CloseChan
+		resumable, _ := payload["d"].(bool)
 
-		return gateway.reconnect()
+		if err = gateway.Handlers.dispatch(dasgo.FlagGatewayEventNameInvalidSession, payloadBytes); err != nil {
+			gateway.reportError(err)
+		}
+
+		return &InvalidSessionError{Resumable: resumable}
 	} else if opcode != dasgo.FlagGatewayOpcodeDispatch {
-		return fmt.Errorf("unexpected opcode when parsing ready event (expected %d, got %f)", dasgo.FlagGatewayOpcodeDispatch, payload["op"].(float64))
+		return fmt.Errorf("unexpected opcode when parsing ready event (expected %d, got %f)", dasgo.FlagGatewayOpcodeDispatch, opcode)
 	}
 
-	check(mapstructure.Decode(payload["d"].(genericMap)["user"].(genericMap), gateway.SelfBot))
+	data, _ := payload["d"].(genericMap)
+	user, _ := data["user"].(genericMap)
+	resumeGatewayURL, _ := data["resume_gateway_url"].(string)
+	sessionID, _ := data["session_id"].(string)
 
-	gateway.GatewayURL = payload["d"].(genericMap)["resume_gateway_url"].(string)
-	gateway.SessionID = payload["d"].(genericMap)["session_id"].(string)
+	if sessionID == "" {
+		return &DecodeError{Event: dasgo.FlagGatewayEventNameReady, Payload: payloadBytes, Err: errMissingField("session_id")}
+	}
 
-	readyEvent := new(dasgo.Ready)
+	if err = mapstructure.Decode(user, gateway.SelfBot); err != nil {
+		return &DecodeError{Event: dasgo.FlagGatewayEventNameReady, Payload: payloadBytes, Err: err}
+	}
 
-	if err = createGatewayEvent(payloadBytes, readyEvent); err != nil {
-		return err
+	gateway.GatewayURL = resumeGatewayURL
+	gateway.SessionID = sessionID
+
+	if seq, ok := payload["s"].(float64); ok {
+		gateway.setLastSeq(seq)
 	}
 
-	for _, handler := range gateway.Handlers.OnReady {
-		go handler(readyEvent)
+	if err = gateway.Handlers.dispatch(dasgo.FlagGatewayEventNameReady, payloadBytes); err != nil {
+		gateway.reportError(err)
 	}
 
 	return nil
 }
 
-func (gateway *Gateway) reconnect() error {
-	return gateway.Connect()
+// resetSession forgets the current session, so that the next connection identifies instead of resuming.
+func (gateway *Gateway) resetSession() {
+	gateway.setLastSeq(0)
+	gateway.SessionID = ""
+	gateway.GatewayURL = ""
 }
 
-func (gateway *Gateway) reset() error {
-	gateway.LastSeq = 0
-	gateway.SessionID = ""
+// reconnectDelay returns how long to wait before connecting again after attempt consecutive failed attempts.
+func reconnectDelay(attempt int) time.Duration {
+	if attempt == 0 {
+		return 0
+	} else if attempt > 6 {
+		return maxReconnectDelay
+	}
 
-	return gateway.reconnect()
+	return time.Second << (attempt - 1)
 }
 
-func (gateway *Gateway) Connect() error {
+// nextSession decides how to carry on after a session ended with err. It returns how long to wait before connecting
+// again, or err itself if the gateway can't carry on.
+func (gateway *Gateway) nextSession(err error, attempt int) (time.Duration, error) {
+	var closeError *CloseError
+	var invalidSession *InvalidSessionError
+
+	switch {
+	case errors.Is(err, errReconnectRequested): // Discord asked for a reconnect, which isn't an error.
+		return 0, nil
+	case errors.As(err, &invalidSession):
+		if !invalidSession.Resumable {
+			gateway.resetSession()
+		}
+
+		gateway.reportError(err)
+
+		return time.Duration(1000+rand.Intn(4000)) * time.Millisecond, nil // Discord asks clients to wait 1-5 seconds.
+	case errors.As(err, &closeError):
+		switch closeError.Code {
+		case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived: // Websocket closed without any close code.
+			gateway.resetSession()
+		case websocket.CloseAbnormalClosure: // The connection dropped without a close frame, so the session is still valid.
+		case dasgo.FlagGatewayCloseEventCodeInvalidSeq.Code, dasgo.FlagGatewayCloseEventCodeSessionTimed.Code: // Reconnectable, but not resumable.
+			gateway.resetSession()
+		default:
+			if !closeError.Reconnect {
+				return 0, err
+			}
+		}
+	}
+
+	gateway.reportError(err)
+
+	return reconnectDelay(attempt), nil
+}
+
+func (gateway *Gateway) dial(ctx context.Context) (*websocket.Conn, error) {
 	if gateway.GatewayURL == "" {
-		gateway.GatewayURL = gatewayURL + gatewayParameters
+		url, err := gateway.REST.GatewayURL()
+
+		if err != nil { // Discovery is only an optimisation, the default URL works too.
+			gateway.reportError(err)
+			url = defaultGatewayURL
+		}
+
+		gateway.GatewayURL = url
 	}
 
 	if len(headers) == 0 {
@@ -366,29 +442,173 @@ func (gateway *Gateway) Connect() error {
 		headers.Set("User-Agent", USER_AGENT)
 	}
 
-	conn, resp, err := websocket.DefaultDialer.Dial(gateway.GatewayURL, headers)
+	dialURL := gateway.GatewayURL + gatewayParameters
 
-	if resp.StatusCode == 404 { // WebSocket URL was invalid, try getting the latest from the API.
-		if err = getGatewayURL(); err != nil {
-			return err
+	if gateway.Compress {
+		dialURL += "&compress=zlib-stream"
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, dialURL, headers)
+
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 { // WebSocket URL was invalid, look up the latest one on the next attempt.
+			gateway.REST.InvalidateGatewayURL()
+			gateway.GatewayURL = ""
 		}
 
-		return gateway.Connect()
-	} else if err != nil {
-		return err
+		return nil, err
+	}
+
+	if gateway.Compress {
+		gateway.inflater = newZlibStream()
+	}
+
+	return conn, nil
+}
+
+// runSession runs a single connection, from dialing it to the end of its event loop. It returns whether the session
+// got as far as READY, and the reason it ended. Every goroutine it starts has exited by the time it returns.
+func (gateway *Gateway) runSession(ctx context.Context) (bool, error) {
+	conn, err := gateway.dial(ctx)
+
+	if err != nil {
+		return false, err
 	}
 
 	gateway.Conn = conn
 
+	var wg sync.WaitGroup
+
+	sessionDone := make(chan struct{})
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+
+	wg.Add(1)
+	go func() { // Closes the connection when the session ends, saying goodbye to Discord first if the gateway is stopping.
+		defer wg.Done()
+
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(closeTimeout))
+
+			timer := time.NewTimer(closeTimeout)
+			defer timer.Stop()
+
+			select { // Wait for Discord to answer, which ends the read loop.
+			case <-sessionDone:
+			case <-timer.C:
+			}
+		case <-sessionDone:
+		}
+
+		conn.Close()
+	}()
+
+	defer func() {
+		close(sessionDone)
+		stopHeartbeat()
+		wg.Wait()
+
+		if gateway.inflater != nil {
+			gateway.inflater.Close()
+			gateway.inflater = nil
+		}
+	}()
+
 	if err = gateway.gatewayHello(); err != nil {
-		return err
-	} else if err = gateway.gatewayIdentify(); err != nil {
-		return err
-	} else if err = gateway.gatewayReady(); err != nil {
-		return err
+		return false, err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		gateway.startHeartbeatSender(heartbeatCtx)
+	}()
+
+	resuming := gateway.canReconnect()
+
+	if err = gateway.gatewayIdentify(); err != nil {
+		return false, err
+	} else if !resuming { // A resumed session gets the missed events and RESUMED instead of READY.
+		if err = gateway.gatewayReady(); err != nil {
+			return false, err
+		}
+	}
+
+	return true, gateway.startMessageHandler()
+}
+
+// Connect connects to the gateway and runs the session until ctx is cancelled, Close is called, or Discord closes the
+// connection with a code that can't be recovered from. Dropped connections are resumed, and errors along the way are
+// passed to OnError. Connect returns nil if it was stopped through ctx or Close.
+func (gateway *Gateway) Connect(ctx context.Context) error {
+	gateway.mutex.Lock()
+
+	if gateway.cancel != nil {
+		gateway.mutex.Unlock()
+		return errors.New("gateway is already connected")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	gateway.cancel = cancel
+	gateway.done = done
+	gateway.mutex.Unlock()
+
+	defer func() {
+		cancel()
+
+		gateway.mutex.Lock()
+		gateway.cancel = nil
+		gateway.done = nil
+		gateway.mutex.Unlock()
+
+		close(done)
+	}()
+
+	for attempt := 0; ; attempt++ {
+		ready, err := gateway.runSession(ctx)
+
+		if ctx.Err() != nil { // The close frame ended the session on Discord's side too.
+			gateway.resetSession()
+			return nil
+		}
+
+		if ready {
+			attempt = 0
+		}
+
+		delay, err := gateway.nextSession(err, attempt)
+
+		if err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			gateway.resetSession()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// Close stops a running Connect call. It sends Discord a close frame, stops every gateway goroutine, and returns once
+// they have all exited. Event handlers that are still running are not waited for.
+func (gateway *Gateway) Close() error {
+	gateway.mutex.Lock()
+	cancel, done := gateway.cancel, gateway.done
+	gateway.mutex.Unlock()
+
+	if cancel == nil {
+		return nil
 	}
 
-	gateway.startMessageHandler()
+	cancel()
+	<-done
 
 	return nil
 }
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/switchupcb/dasgo/dasgo"
+)
+
+// fakeGateway is a minimal stand-in for Discord's gateway: it says hello, answers identify with READY, resume with
+// RESUMED, and acknowledges heartbeats.
+type fakeGateway struct {
+	server      *httptest.Server
+	url         string
+	closeCodes  chan int      // Close codes sent by the client.
+	heartbeats  chan struct{} // One value per heartbeat received.
+	resumes     chan string   // Session IDs the client resumed.
+	reconnectAt atomic.Int32  // Ask for a reconnect after READY on this many connections.
+}
+
+func newFakeGateway(t *testing.T) *fakeGateway {
+	t.Helper()
+
+	fake := &fakeGateway{
+		closeCodes: make(chan int, 4),
+		heartbeats: make(chan struct{}, 256),
+		resumes:    make(chan string, 4),
+	}
+
+	upgrader := websocket.Upgrader{}
+
+	fake.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetCloseHandler(func(code int, text string) error {
+			fake.closeCodes <- code
+			return conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+		})
+
+		conn.WriteJSON(genericMap{"op": dasgo.FlagGatewayOpcodeHello, "d": genericMap{"heartbeat_interval": 20}})
+
+		for {
+			var payload struct {
+				Op   int             `json:"op"`
+				Data json.RawMessage `json:"d"`
+			}
+
+			if err := conn.ReadJSON(&payload); err != nil {
+				return
+			}
+
+			switch payload.Op {
+			case dasgo.FlagGatewayOpcodeHeartbeat:
+				select {
+				case fake.heartbeats <- struct{}{}:
+				default:
+				}
+
+				conn.WriteJSON(genericMap{"op": dasgo.FlagGatewayOpcodeHeartbeatACK})
+			case dasgo.FlagGatewayOpcodeIdentify:
+				conn.WriteJSON(genericMap{"op": dasgo.FlagGatewayOpcodeDispatch, "t": dasgo.FlagGatewayEventNameReady, "s": 1, "d": genericMap{
+					"v":                  9,
+					"user":               genericMap{"id": "1072069875993956372", "username": "skifli"},
+					"session_id":         "fake-session",
+					"resume_gateway_url": fake.url,
+				}})
+
+				if fake.reconnectAt.Add(-1) >= 0 {
+					conn.WriteJSON(genericMap{"op": dasgo.FlagGatewayOpcodeReconnect, "d": nil})
+				}
+			case dasgo.FlagGatewayOpcodeResume:
+				var resume struct {
+					SessionID string `json:"session_id"`
+				}
+
+				json.Unmarshal(payload.Data, &resume)
+				fake.resumes <- resume.SessionID
+
+				conn.WriteJSON(genericMap{"op": dasgo.FlagGatewayOpcodeDispatch, "t": dasgo.FlagGatewayEventNameResumed, "s": 2, "d": genericMap{}})
+			}
+		}
+	}))
+
+	fake.url = "ws" + strings.TrimPrefix(fake.server.URL, "http") + "/"
+
+	return fake
+}
+
+// checkGoroutines fails the test if more goroutines are running than before, once stragglers have had time to exit.
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			var stacks bytes.Buffer
+			pprof.Lookup("goroutine").WriteTo(&stacks, 1)
+
+			t.Fatalf("%d goroutines leaked:\n%s", runtime.NumGoroutine()-before, stacks.String())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitFor[T any](t *testing.T, values <-chan T, what string) T {
+	t.Helper()
+
+	select {
+	case value := <-values:
+		return value
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+
+		var zero T
+		return zero
+	}
+}
+
+func startGateway(t *testing.T, fake *fakeGateway, ctx context.Context) (*Gateway, <-chan error) {
+	t.Helper()
+
+	gateway := CreateGateway(new(SelfBot))
+	gateway.GatewayURL = fake.url
+	gateway.OnError = func(err error) {
+		t.Logf("gateway error: %v", err)
+	}
+
+	ready := make(chan struct{}, 1)
+	On(gateway.Handlers, func(*dasgo.Ready) { ready <- struct{}{} })
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- gateway.Connect(ctx)
+	}()
+
+	waitFor(t, ready, "READY")
+
+	return gateway, errs
+}
+
+func TestConnectClose(t *testing.T) {
+	fake := newFakeGateway(t)
+	defer fake.server.Close()
+
+	before := runtime.NumGoroutine()
+
+	gateway, errs := startGateway(t, fake, context.Background())
+
+	waitFor(t, fake.heartbeats, "a heartbeat")
+	waitFor(t, fake.heartbeats, "a second heartbeat")
+
+	if err := gateway.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("Connect returned %v", err)
+		}
+	default:
+		t.Fatal("Close returned before Connect")
+	}
+
+	if code := waitFor(t, fake.closeCodes, "a close frame"); code != websocket.CloseNormalClosure {
+		t.Errorf("got close code %d, want %d", code, websocket.CloseNormalClosure)
+	}
+	if gateway.SessionID != "" {
+		t.Errorf("session %q survived Close", gateway.SessionID)
+	}
+
+	checkGoroutines(t, before)
+}
+
+func TestConnectContextCancel(t *testing.T) {
+	fake := newFakeGateway(t)
+	defer fake.server.Close()
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, errs := startGateway(t, fake, ctx)
+
+	cancel()
+
+	if err := waitFor(t, errs, "Connect to return"); err != nil {
+		t.Fatalf("Connect returned %v", err)
+	}
+
+	waitFor(t, fake.closeCodes, "a close frame")
+	checkGoroutines(t, before)
+}
+
+func TestConnectResumesAfterReconnect(t *testing.T) {
+	fake := newFakeGateway(t)
+	defer fake.server.Close()
+
+	fake.reconnectAt.Store(1)
+
+	before := runtime.NumGoroutine()
+
+	gateway, errs := startGateway(t, fake, context.Background())
+
+	if sessionID := waitFor(t, fake.resumes, "a resume"); sessionID != "fake-session" {
+		t.Errorf("resumed session %q, want fake-session", sessionID)
+	}
+
+	if err := gateway.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Connect returned %v", err)
+	}
+
+	checkGoroutines(t, before)
+}
+
+func TestReconnectDelay(t *testing.T) {
+	for attempt, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := reconnectDelay(attempt); got != want {
+			t.Errorf("attempt %d: got %v, want %v", attempt, got, want)
+		}
+	}
+
+	if got := reconnectDelay(100); got != maxReconnectDelay {
+		t.Errorf("got %v after 100 attempts, want %v", got, maxReconnectDelay)
+	}
+}
@@ -1,7 +0,0 @@
-package api
-
-func check(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+
+	"github.com/goccy/go-json"
+)
+
+// zlibSuffix marks the end of a complete zlib-stream message: Discord
+// flushes the shared deflate context with Z_SYNC_FLUSH after each one.
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+var errZlibStreamClosed = errors.New("zlib stream closed")
+
+// zlibStream decompresses a Gateway's connection when Compress is
+// set. A new one is made for every connection, since the deflate context
+// spans all of the connection's frames and can't be carried over to a
+// resumed one.
+//
+// The bot in the repository root has its own gateway client with the same
+// inflater in zlib.go. gocord is a separate module the bot doesn't import,
+// so the code can't be shared; fixes to one belong in the other too.
+//
+// The inflater goroutine reads from a pipe so that it blocks between
+// frames, where a drained bytes.Reader would end the flate stream.
+type zlibStream struct {
+	pending  []byte
+	pipe     *io.PipeWriter
+	messages chan zlibResult
+}
+
+type zlibResult struct {
+	data []byte
+	err  error
+}
+
+func newZlibStream() *zlibStream {
+	reader, writer := io.Pipe()
+
+	z := &zlibStream{
+		pipe:     writer,
+		messages: make(chan zlibResult, 1),
+	}
+
+	go z.inflate(reader)
+
+	return z
+}
+
+func (z *zlibStream) inflate(reader *io.PipeReader) {
+	defer close(z.messages)
+
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		reader.CloseWithError(err)
+		z.messages <- zlibResult{err: err}
+		return
+	}
+	defer inflater.Close()
+
+	decoder := json.NewDecoder(inflater)
+	for {
+		var message json.RawMessage
+		if err := decoder.Decode(&message); err != nil {
+			reader.CloseWithError(err)
+			z.messages <- zlibResult{err: err}
+			return
+		}
+
+		z.messages <- zlibResult{data: message}
+	}
+}
+
+// Feed passes a websocket frame to the inflater. Frames are buffered until
+// one ends in zlibSuffix, and then the whole message's payload is returned;
+// until then Feed returns nil.
+func (z *zlibStream) Feed(frame []byte) ([]byte, error) {
+	z.pending = append(z.pending, frame...)
+	if !bytes.HasSuffix(z.pending, zlibSuffix) {
+		return nil, nil
+	}
+
+	if _, err := z.pipe.Write(z.pending); err != nil {
+		return nil, err
+	}
+	z.pending = z.pending[:0]
+
+	result, ok := <-z.messages
+	if !ok {
+		return nil, errZlibStreamClosed
+	}
+
+	return result.data, result.err
+}
+
+// Close stops the inflater goroutine. The Gateway calls it when the
+// connection it belongs to is closed.
+func (z *zlibStream) Close() error {
+	return z.pipe.Close()
+}
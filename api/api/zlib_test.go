@@ -0,0 +1,74 @@
+package api
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return lines
+}
+
+func TestZlibStreamRecordedFrames(t *testing.T) {
+	frames := readLines(t, "testdata/zlib-stream.frames")
+	expected := readLines(t, "testdata/zlib-stream.payloads")
+
+	stream := newZlibStream()
+	defer stream.Close()
+
+	var decoded []string
+	for i, line := range frames {
+		frame, err := hex.DecodeString(line)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+
+		payload, err := stream.Feed(frame)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if payload != nil {
+			decoded = append(decoded, string(payload))
+		}
+	}
+
+	if len(decoded) != len(expected) {
+		t.Fatalf("decoded %d payloads, want %d", len(decoded), len(expected))
+	}
+
+	for i := range expected {
+		if decoded[i] != expected[i] {
+			t.Errorf("payload %d mismatch:\n got %.200s\nwant %.200s", i, decoded[i], expected[i])
+		}
+	}
+
+	payload := make(genericMap)
+	if err := json.Unmarshal([]byte(decoded[1]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["t"] != "READY" {
+		t.Errorf("got event %v, want READY", payload["t"])
+	}
+}
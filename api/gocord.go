@@ -1,6 +1,7 @@
 package gocord
 
 import (
+	"context"
 	"errors"
 
 	"github.com/skifli/gocord/api"
@@ -8,8 +9,13 @@ import (
 
 // Client represents a Discord self-bot.
 type Client struct {
-	Gateway *api.Gateway // Gateway contains data relating to a Discord WebSocket connection.
-	SelfBot *api.SelfBot // SelfBot contains data relating to the self-bot.
+	Gateway  *api.Gateway // Gateway contains data relating to a Discord WebSocket connection.
+	SelfBot  *api.SelfBot // SelfBot contains data relating to the self-bot.
+	Compress bool         // Compress enables zlib-stream compression on the gateway connection.
+
+	// OnError is called with errors that happen while the client runs in the background, e.g. an *api.CloseError
+	// when Discord closes the connection, an *api.DecodeError for a malformed event, or an *api.InvalidSessionError.
+	OnError func(err error)
 }
 
 // Creates a SelfBot struct, used when initializing a Client struct.
@@ -27,14 +33,28 @@ func (client *Client) Init() error {
 	}
 
 	client.Gateway = api.CreateGateway(client.SelfBot)
+	client.Gateway.Compress = client.Compress
+	client.Gateway.OnError = client.OnError
 
 	return nil
 }
 
-func (client *Client) Connect() error {
-	return client.Gateway.Connect()
+// Connect connects the self-bot to the gateway, and blocks until ctx is cancelled, Close is called, or the connection
+// fails in a way that can't be recovered from.
+func (client *Client) Connect(ctx context.Context) error {
+	return client.Gateway.Connect(ctx)
+}
+
+// Close disconnects the self-bot, and returns once every gateway goroutine has exited.
+func (client *Client) Close() error {
+	return client.Gateway.Close()
 }
 
 func (client *Client) AddEventHandler(event string, function any) error {
 	return client.Gateway.Handlers.Add(event, function)
 }
+
+// On registers a handler for the gateway event T. The returned handler can be removed again with Remove.
+func On[T api.Event](client *Client, function func(*T)) *api.Handler {
+	return api.On(client.Gateway.Handlers, function)
+}
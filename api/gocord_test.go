@@ -1,6 +1,7 @@
 package gocord
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -28,5 +29,5 @@ func TestMain(t *testing.T) {
 		fmt.Printf("%#v\n\n", event)
 	})
 
-	check(client.Connect())
+	check(client.Connect(context.Background()))
 }
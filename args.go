@@ -0,0 +1,397 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ArgType is the kind of value an argument or flag takes.
+type ArgType int
+
+const (
+	ArgString   ArgType = iota
+	ArgInt              // A whole number, within Min and Max if either is set.
+	ArgUser             // A user mention or ID, parsed to the ID.
+	ArgChannel          // A channel mention or ID, parsed to the ID.
+	ArgDuration         // A Go duration like 90s or 1h30m, or a number of days like 2d.
+	ArgURL              // An absolute http or https URL.
+	ArgBool             // A flag that takes no value.
+)
+
+// ArgSpec declares a positional argument.
+type ArgSpec struct {
+	Name     string
+	Type     ArgType
+	Required bool
+	Rest     bool   // Rest takes the rest of the command line, spacing and quotes included. Only the last argument can be Rest.
+	Default  string // Default is parsed as if it had been given when the argument is left out.
+	Min, Max int    // Min and Max bound an ArgInt, when either isn't 0.
+}
+
+// FlagSpec declares a --flag. Flags can come anywhere before a Rest argument.
+type FlagSpec struct {
+	Name  string // Name is the long form, without the dashes.
+	Short string // Short is the single letter form, if there is one.
+	Type  ArgType
+	Value string // Value names the flag's value in usage, if it isn't obvious from Type.
+}
+
+// Args is a command line parsed against a command's spec.
+type Args struct {
+	Raw    []string // Raw holds the positional arguments as given, unquoted.
+	values map[string]any
+}
+
+// UsageError is returned when a command line doesn't match the command's
+// spec. Its message ends with the command's usage.
+type UsageError struct {
+	Command *Command
+	Problem string
+}
+
+func (err *UsageError) Error() string {
+	return fmt.Sprintf("%s\nUsage: %s", err.Problem, err.Command.usageLine())
+}
+
+func (args *Args) value(name string) any {
+	return args.values[name]
+}
+
+// Has reports whether the argument or flag was given or has a default.
+func (args *Args) Has(name string) bool {
+	_, ok := args.values[name]
+	return ok
+}
+
+func (args *Args) String(name string) string {
+	value, _ := args.value(name).(string)
+	return value
+}
+
+func (args *Args) Int(name string) int {
+	value, _ := args.value(name).(int)
+	return value
+}
+
+func (args *Args) Bool(name string) bool {
+	value, _ := args.value(name).(bool)
+	return value
+}
+
+func (args *Args) Duration(name string) time.Duration {
+	value, _ := args.value(name).(time.Duration)
+	return value
+}
+
+func (args *Args) URL(name string) *url.URL {
+	value, _ := args.value(name).(*url.URL)
+	return value
+}
+
+// argToken is a word of the command line, and where it started.
+type argToken struct {
+	value  string
+	offset int
+}
+
+// splitArgs splits a command line on whitespace. A double or single quote at
+// the start of a word groups everything up to the matching quote into one
+// argument, and a backslash escapes the next character. Quotes inside a word,
+// as in "don't", and quotes that are never closed are kept as they are.
+func splitArgs(line string) []argToken {
+	var tokens []argToken
+
+	runes := []rune(line)
+	offsets := make([]int, len(runes)+1)
+	for i, offset := 0, 0; i < len(runes); i++ {
+		offsets[i] = offset
+		offset += len(string(runes[i]))
+	}
+	offsets[len(runes)] = len(line)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		var word strings.Builder
+
+		if quote := runes[i]; quote == '"' || quote == '\'' {
+			if end := closingQuote(runes, i+1, quote); end >= 0 {
+				for j := i + 1; j < end; j++ {
+					if runes[j] == '\\' && j+1 < end && (runes[j+1] == quote || runes[j+1] == '\\') {
+						j++
+					}
+					word.WriteRune(runes[j])
+				}
+				tokens = append(tokens, argToken{value: word.String(), offset: offsets[start]})
+				i = end + 1
+				continue
+			}
+		}
+
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if runes[i] == '\\' && i+1 < len(runes) {
+				i++
+			}
+			word.WriteRune(runes[i])
+			i++
+		}
+		tokens = append(tokens, argToken{value: word.String(), offset: offsets[start]})
+	}
+
+	return tokens
+}
+
+func closingQuote(runes []rune, from int, quote rune) int {
+	for i := from; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseArgs parses line, the command line after the command name, against
+// command's spec.
+func parseArgs(command *Command, line string) (*Args, error) {
+	args := &Args{values: make(map[string]any)}
+	tokens := splitArgs(line)
+
+	var positional []argToken
+	flagsDone := len(command.Flags) == 0
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		if !flagsDone && token.value == "--" {
+			flagsDone = true
+			continue
+		}
+
+		if flagsDone || !isFlag(token.value) {
+			// A Rest argument takes everything from here on, flags included.
+			if n := len(positional); n < len(command.Args) && command.Args[n].Rest {
+				positional = append(positional, tokens[i:]...)
+				break
+			}
+			positional = append(positional, token)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(token.value, "-"), "=")
+		flag, ok := command.flag(name, !strings.HasPrefix(token.value, "--"))
+		if !ok {
+			return nil, &UsageError{command, fmt.Sprintf("Unknown flag %s", token.value)}
+		}
+
+		if flag.Type == ArgBool {
+			if hasValue {
+				return nil, &UsageError{command, fmt.Sprintf("--%s doesn't take a value", flag.Name)}
+			}
+			args.values[flag.Name] = true
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, &UsageError{command, fmt.Sprintf("--%s needs a value", flag.Name)}
+			}
+			i++
+			value = tokens[i].value
+		}
+
+		parsed, err := parseArgValue(flag.Type, value, 0, 0)
+		if err != nil {
+			return nil, &UsageError{command, fmt.Sprintf("--%s: %v", flag.Name, err)}
+		}
+		args.values[flag.Name] = parsed
+	}
+
+	for _, token := range positional {
+		args.Raw = append(args.Raw, token.value)
+	}
+
+	if len(command.Args) == 0 {
+		return args, nil
+	}
+
+	for i, spec := range command.Args {
+		var text string
+		switch {
+		case i >= len(positional):
+			if spec.Required {
+				return nil, &UsageError{command, fmt.Sprintf("Missing %s", spec.Name)}
+			}
+			if spec.Default == "" {
+				continue
+			}
+			text = spec.Default
+		case spec.Rest && len(positional)-i > 1:
+			text = strings.TrimSpace(line[positional[i].offset:])
+		default:
+			text = positional[i].value
+		}
+
+		value, err := parseArgValue(spec.Type, text, spec.Min, spec.Max)
+		if err != nil {
+			return nil, &UsageError{command, fmt.Sprintf("%s: %v", spec.Name, err)}
+		}
+		args.values[spec.Name] = value
+	}
+
+	if last := command.Args[len(command.Args)-1]; !last.Rest && len(positional) > len(command.Args) {
+		return nil, &UsageError{command, fmt.Sprintf("Unexpected argument %q", positional[len(command.Args)].value)}
+	}
+
+	return args, nil
+}
+
+// isFlag reports whether a word looks like a flag rather than a value, so
+// that negative numbers are left alone.
+func isFlag(word string) bool {
+	if len(word) < 2 || word[0] != '-' {
+		return false
+	}
+	_, err := strconv.ParseFloat(word, 64)
+	return err != nil
+}
+
+func (command *Command) flag(name string, short bool) (FlagSpec, bool) {
+	for _, flag := range command.Flags {
+		if (short && flag.Short == name) || (!short && flag.Name == name) {
+			return flag, true
+		}
+	}
+	return FlagSpec{}, false
+}
+
+var (
+	userMention    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	channelMention = regexp.MustCompile(`^<#(\d+)>$`)
+	dayDuration    = regexp.MustCompile(`^(\d+)d$`)
+)
+
+func parseArgValue(argType ArgType, text string, min, max int) (any, error) {
+	switch argType {
+	case ArgInt:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a number", text)
+		}
+		if (min != 0 || max != 0) && n < min {
+			if max != 0 {
+				return nil, fmt.Errorf("has to be between %d and %d", min, max)
+			}
+			return nil, fmt.Errorf("has to be at least %d", min)
+		}
+		if max != 0 && n > max {
+			return nil, fmt.Errorf("has to be between %d and %d", min, max)
+		}
+		return n, nil
+
+	case ArgUser:
+		if match := userMention.FindStringSubmatch(text); match != nil {
+			return match[1], nil
+		}
+		if isSnowflake(text) {
+			return text, nil
+		}
+		return nil, fmt.Errorf("%q isn't a user mention or ID", text)
+
+	case ArgChannel:
+		if match := channelMention.FindStringSubmatch(text); match != nil {
+			return match[1], nil
+		}
+		if isSnowflake(text) {
+			return text, nil
+		}
+		return nil, fmt.Errorf("%q isn't a channel mention or ID", text)
+
+	case ArgDuration:
+		if match := dayDuration.FindStringSubmatch(text); match != nil {
+			days, _ := strconv.Atoi(match[1])
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%q isn't a duration like 30s, 5m or 2d", text)
+		}
+		return d, nil
+
+	case ArgURL:
+		u, err := url.ParseRequestURI(text)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%q isn't an http(s) URL", text)
+		}
+		return u, nil
+
+	case ArgBool:
+		return nil, errors.New("flags that don't take a value can't be arguments")
+	}
+
+	return text, nil
+}
+
+// usage generates the argument part of a command's usage line from its spec.
+// Flags are listed after the arguments, unless the last argument takes the
+// rest of the line and so has to come after them.
+func (command *Command) usage() string {
+	var args, flags []string
+
+	for _, spec := range command.Args {
+		name := spec.Name
+		if spec.Rest {
+			name += "..."
+		}
+		if spec.Required {
+			args = append(args, "<"+name+">")
+		} else {
+			args = append(args, "["+name+"]")
+		}
+	}
+
+	for _, flag := range command.Flags {
+		part := "--" + flag.Name
+		if flag.Type != ArgBool {
+			part += " " + flag.valueName()
+		}
+		flags = append(flags, "["+part+"]")
+	}
+
+	if n := len(command.Args); n > 0 && command.Args[n-1].Rest {
+		return strings.Join(append(flags, args...), " ")
+	}
+	return strings.Join(append(args, flags...), " ")
+}
+
+func (flag FlagSpec) valueName() string {
+	if flag.Value != "" {
+		return flag.Value
+	}
+
+	switch flag.Type {
+	case ArgInt:
+		return "number"
+	case ArgUser:
+		return "@user"
+	case ArgChannel:
+		return "#channel"
+	case ArgDuration:
+		return "duration"
+	case ArgURL:
+		return "url"
+	}
+	return "text"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := map[string][]string{
+		"":                        nil,
+		"  hi  ":                  {"hi"},
+		"a  b\tc\nd":              {"a", "b", "c", "d"},
+		`"hello world" x`:         {"hello world", "x"},
+		`'single quoted' "a\"b"`:  {"single quoted", `a"b`},
+		`don't stop`:              {"don't", "stop"},
+		`"unterminated quote`:     {`"unterminated`, "quote"},
+		`back\ slash`:             {"back slash"},
+		`"" empty`:                {"", "empty"},
+		`émoji "ünïcode words" 🙂`: {"émoji", "ünïcode words", "🙂"},
+	}
+
+	for line, want := range tests {
+		var got []string
+		for _, token := range splitArgs(line) {
+			got = append(got, token.value)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+var testCommand = &Command{
+	Name: "test",
+	Args: []ArgSpec{
+		{Name: "count", Type: ArgInt, Required: true, Min: 1, Max: 10},
+		{Name: "user", Type: ArgUser},
+		{Name: "text", Rest: true},
+	},
+	Flags: []FlagSpec{
+		{Name: "channel", Short: "c", Type: ArgChannel},
+		{Name: "every", Type: ArgDuration},
+		{Name: "link", Type: ArgURL},
+		{Name: "quiet", Short: "q", Type: ArgBool},
+	},
+}
+
+func TestParseArgs(t *testing.T) {
+	args, err := parseArgs(testCommand, ` -q --channel <#123456789012345678> --every=2d 3 <@!223456789012345678>  keep   "spacing" --here`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args.Int("count") != 3 || args.String("user") != "223456789012345678" {
+		t.Errorf("got count %d, user %q", args.Int("count"), args.String("user"))
+	}
+	if got := args.String("text"); got != `keep   "spacing" --here` {
+		t.Errorf("got text %q", got)
+	}
+	if !args.Bool("quiet") || args.String("channel") != "123456789012345678" || args.Duration("every") != 48*time.Hour {
+		t.Errorf("got flags quiet=%v channel=%q every=%v", args.Bool("quiet"), args.String("channel"), args.Duration("every"))
+	}
+	if args.Has("link") {
+		t.Error("link is set without being given")
+	}
+
+	args, err = parseArgs(testCommand, `5 223456789012345678 "one quoted arg" --link https://example.com/x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := args.String("text"); got != `"one quoted arg" --link https://example.com/x` {
+		t.Errorf("got text %q", got)
+	}
+
+	args, err = parseArgs(testCommand, `--link https://example.com/x 5 223456789012345678 "one quoted arg"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.String("text") != "one quoted arg" || args.URL("link").Host != "example.com" {
+		t.Errorf("got text %q, link %v", args.String("text"), args.URL("link"))
+	}
+}
+
+func TestParseArgsUsageErrors(t *testing.T) {
+	configStore.Replace(Config{Prefix: "&"})
+
+	tests := map[string]string{
+		"":                     "Missing count",
+		"zero":                 `count: "zero" isn't a number`,
+		"11":                   "count: has to be between 1 and 10",
+		"-1":                   "count: has to be between 1 and 10",
+		"1 bob":                `user: "bob" isn't a user mention or ID`,
+		"1 --loud":             "Unknown flag --loud",
+		"1 --channel":          "--channel needs a value",
+		"1 --channel general":  `--channel: "general" isn't a channel mention or ID`,
+		"1 --every soon":       `--every: "soon" isn't a duration like 30s, 5m or 2d`,
+		"1 --link example.com": `--link: "example.com" isn't an http(s) URL`,
+		"1 --quiet=yes":        "--quiet doesn't take a value",
+	}
+
+	for line, want := range tests {
+		_, err := parseArgs(testCommand, line)
+
+		var usageErr *UsageError
+		if !errors.As(err, &usageErr) {
+			t.Errorf("%q: got %v, want a *UsageError", line, err)
+			continue
+		}
+		if usageErr.Problem != want {
+			t.Errorf("%q: got %q, want %q", line, usageErr.Problem, want)
+		}
+		if !strings.HasSuffix(err.Error(), "Usage: &test [--channel #channel] [--every duration] [--link url] [--quiet] <count> [user] [text...]") {
+			t.Errorf("%q: usage missing from %q", line, err.Error())
+		}
+	}
+}
+
+func TestParseArgsTooMany(t *testing.T) {
+	command := &Command{Name: "roll", Args: []ArgSpec{{Name: "sides", Type: ArgInt, Default: "6"}}}
+
+	args, err := parseArgs(command, "")
+	if err != nil || args.Int("sides") != 6 {
+		t.Errorf("got %d, %v without arguments", args.Int("sides"), err)
+	}
+
+	if _, err := parseArgs(command, "6 7"); err == nil {
+		t.Error("an extra argument didn't fail")
+	}
+
+	// Commands that don't declare arguments take anything, flags included.
+	args, err = parseArgs(&Command{Name: "legacy"}, `--not-a-flag "two words"`)
+	if err != nil || !reflect.DeepEqual(args.Raw, []string{"--not-a-flag", "two words"}) {
+		t.Errorf("got %q, %v", args.Raw, err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultSendsPerMinute = 60
+	defaultSendBurst      = 10
+)
+
+// sendBudget limits everything the account sends, across every feature.
+var sendBudget = NewSendBudget(defaultSendsPerMinute, defaultSendBurst)
+
+// SendBudgetStats describes a send budget and how much it has held back.
+type SendBudgetStats struct {
+	PerMinute int     `json:"per_minute"`
+	Burst     int     `json:"burst"`
+	Available float64 `json:"available"`
+	Sent      uint64  `json:"sent"`
+	Delayed   uint64  `json:"delayed"`
+	WaitedMS  int64   `json:"waited_ms"`
+}
+
+// SendBudget is a token bucket shared by every outbound message, edit,
+// reaction and delete. It holds up to burst tokens and refills perMinute of
+// them a minute; each send takes one, waiting for it if the bucket is empty.
+// Waiting senders reserve their token, so they're let through in order.
+type SendBudget struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens a second.
+	burst  float64
+	tokens float64 // Negative while senders are waiting on reserved tokens.
+	last   time.Time
+
+	sent    uint64
+	delayed uint64
+	waited  time.Duration
+}
+
+func NewSendBudget(perMinute, burst int) *SendBudget {
+	budget := &SendBudget{last: time.Now()}
+	budget.SetLimit(perMinute, burst)
+	budget.tokens = budget.burst
+	return budget
+}
+
+// SetLimit changes the budget's rate and burst. Values under 1 fall back to
+// the defaults.
+func (budget *SendBudget) SetLimit(perMinute, burst int) {
+	if perMinute < 1 {
+		perMinute = defaultSendsPerMinute
+	}
+	if burst < 1 {
+		burst = defaultSendBurst
+	}
+
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	budget.refill(time.Now())
+	budget.rate = float64(perMinute) / 60
+	budget.burst = float64(burst)
+	budget.tokens = min(budget.tokens, budget.burst)
+}
+
+// Wait takes a token, sleeping until one is available.
+func (budget *SendBudget) Wait() {
+	budget.mu.Lock()
+
+	now := time.Now()
+	budget.refill(now)
+	budget.tokens--
+	budget.sent++
+
+	var delay time.Duration
+	if budget.tokens < 0 {
+		delay = time.Duration(-budget.tokens / budget.rate * float64(time.Second))
+		budget.delayed++
+		budget.waited += delay
+	}
+
+	budget.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// refill adds the tokens earned since the last refill. The lock must be held.
+func (budget *SendBudget) refill(now time.Time) {
+	budget.tokens = min(budget.burst, budget.tokens+now.Sub(budget.last).Seconds()*budget.rate)
+	budget.last = now
+}
+
+func (budget *SendBudget) Stats() SendBudgetStats {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	budget.refill(time.Now())
+
+	return SendBudgetStats{
+		PerMinute: int(budget.rate*60 + 0.5),
+		Burst:     int(budget.burst),
+		Available: max(budget.tokens, 0),
+		Sent:      budget.sent,
+		Delayed:   budget.delayed,
+		WaitedMS:  budget.waited.Milliseconds(),
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendBudget(t *testing.T) {
+	budget := NewSendBudget(1200, 3) // A token every 50ms.
+
+	start := time.Now()
+	for range 3 {
+		budget.Wait()
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("the burst took %v", elapsed)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			budget.Wait()
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("4 sends past the burst took %v, want about 200ms", elapsed)
+	}
+
+	stats := budget.Stats()
+	if stats.PerMinute != 1200 || stats.Burst != 3 || stats.Sent != 7 || stats.Delayed != 4 {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestSendBudgetSetLimit(t *testing.T) {
+	budget := NewSendBudget(60, 10)
+
+	budget.SetLimit(30, 2)
+	if stats := budget.Stats(); stats.PerMinute != 30 || stats.Burst != 2 || stats.Available > 2 {
+		t.Errorf("got %+v after lowering the limit", stats)
+	}
+
+	budget.SetLimit(0, -1)
+	if stats := budget.Stats(); stats.PerMinute != defaultSendsPerMinute || stats.Burst != defaultSendBurst {
+		t.Errorf("got %+v, want the defaults", stats)
+	}
+}
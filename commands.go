@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Command is a chat command. Help and the category listings are generated
+// from the registered commands, so a command only has to be described here.
+type Command struct {
+	Name        string
+	Aliases     []string
+	Category    string
+	Description string
+	Args        []ArgSpec     // Args declares the positional arguments. Commands without any accept whatever they're given.
+	Flags       []FlagSpec    // Flags declares the --flags. Commands without any take words starting with - as arguments.
+	KeepMessage bool          // KeepMessage leaves the command message in place instead of deleting it.
+	Cooldown    time.Duration // Cooldown is how long after running the command has to wait before it runs again.
+	Handler     func(message Message, args *Args)
+}
+
+type commandCategory struct {
+	name        string
+	description string
+}
+
+// categories are listed in help in this order. Each also gets a command,
+// named after it in lower case, that lists its commands.
+var categories = []commandCategory{
+	{"Utilities", "Usefull stuff"},
+	{"Fun", "Kinda fun commands"},
+	{"Info", "Information and statistics"},
+	{"NSFW", "Not safe for work"},
+}
+
+var (
+	commandList []*Command          // In registration order, for help.
+	commandMap  map[string]*Command // By name and alias.
+
+	cooldownMutex sync.Mutex
+	lastRun       = make(map[*Command]time.Time)
+)
+
+// The registry is filled in init, since the help commands read it.
+func init() {
+	commandMap = make(map[string]*Command)
+
+	registerCommand(&Command{Name: "help", Description: "Show this help message, or detailed help on a command", Args: []ArgSpec{{Name: "command"}}, Handler: handleHelp})
+	registerCommand(&Command{Name: "categories", Description: "Show all command categories", Handler: noArgs(handleCategories)})
+	for _, category := range categories {
+		category := category
+		registerCommand(&Command{
+			Name:        strings.ToLower(category.name),
+			Description: fmt.Sprintf("Show %s commands", strings.ToLower(category.name)),
+			Handler:     func(message Message, _ *Args) { handleCategory(message, category) },
+		})
+	}
+
+	registerCommand(&Command{Name: "ping", Category: "Utilities", Description: "Check gateway and REST latency", Handler: noArgs(handlePing)})
+	registerCommand(&Command{
+		Name:        "clear",
+		Category:    "Utilities",
+		Description: "Delete your own messages",
+		Args:        []ArgSpec{{Name: "count", Type: ArgInt, Default: "10", Min: 1}},
+		Flags: []FlagSpec{
+			{Name: "contains", Short: "c"},
+			{Name: "before", Value: "date|id"},
+			{Name: "after", Value: "date|id"},
+			{Name: "attachments-only", Short: "a", Type: ArgBool},
+			{Name: "dry-run", Short: "n", Type: ArgBool},
+		},
+		Cooldown: 10 * time.Second,
+		Handler:  handleClear,
+	})
+	registerCommand(&Command{Name: "weather", Category: "Utilities", Description: "Get current weather (api became payed)", Args: []ArgSpec{{Name: "location", Rest: true}}, Cooldown: 5 * time.Second, Handler: handleWeather})
+	registerCommand(&Command{Name: "ar", Category: "Utilities", Description: "Toggle auto responder", Handler: noArgs(handleAutoResponder)})
+	registerCommand(&Command{Name: "setphrase", Category: "Utilities", Description: "Show or set the auto responder phrase", Args: []ArgSpec{{Name: "phrase", Rest: true}}, Handler: rawArgs(handleSetPhrase)})
+	registerCommand(&Command{Name: "react", Category: "Utilities", Description: "Auto-react to your messages with an emoji", Args: []ArgSpec{{Name: "emoji|off", Rest: true}}, Handler: rawArgs(handleReact)})
+	registerCommand(&Command{
+		Name:        "ap",
+		Category:    "Utilities",
+		Description: "Start autopressure on user, optionally for a while, or stop it",
+		Args:        []ArgSpec{{Name: "user", Type: ArgUser}},
+		Flags:       []FlagSpec{{Name: "for", Type: ArgDuration}, {Name: "stop", Short: "s", Type: ArgBool}},
+		Handler:     handleAutoPressure,
+	})
+	registerCommand(&Command{Name: "status", Category: "Utilities", Description: "Change Discord status", Args: []ArgSpec{{Name: "online|idle|dnd|invisible", Required: true}, {Name: "text", Rest: true}}, Cooldown: 10 * time.Second, Handler: rawArgs(handleStatus)})
+	registerCommand(&Command{Name: "ip", Category: "Utilities", Description: "Lookup IP information (default: your own)", Args: []ArgSpec{{Name: "address"}}, Cooldown: 5 * time.Second, Handler: rawArgs(handleIPLookup)})
+	registerCommand(&Command{Name: "encode", Category: "Utilities", Description: "Encode input to base64", Args: []ArgSpec{{Name: "input", Required: true, Rest: true}}, Handler: rawArgs(handleEncode)})
+	registerCommand(&Command{Name: "decode", Category: "Utilities", Description: "Decode base64 to text", Args: []ArgSpec{{Name: "base64", Required: true, Rest: true}}, Handler: rawArgs(handleDecode)})
+	registerCommand(&Command{Name: "password", Category: "Utilities", Description: "Generate a secure password", Args: []ArgSpec{{Name: "length", Type: ArgInt, Default: "16", Min: 1, Max: 100}}, Handler: handlePassword})
+	registerCommand(&Command{Name: "ai", Category: "Utilities", Description: "Get ai results (removed)", Args: []ArgSpec{{Name: "prompt", Rest: true}}, Handler: noArgs(handleAI)})
+	registerCommand(&Command{Name: "shorten", Category: "Utilities", Description: "Shorten a URL", Args: []ArgSpec{{Name: "url", Type: ArgURL, Required: true}}, Cooldown: 5 * time.Second, Handler: handleShortenURL})
+	registerCommand(&Command{Name: "google", Category: "Utilities", Description: "Googles something", Args: []ArgSpec{{Name: "query", Required: true, Rest: true}}, Cooldown: 5 * time.Second, Handler: rawArgs(handleGoogleSearch)})
+	registerCommand(&Command{
+		Name:        "say",
+		Category:    "Utilities",
+		Description: "Send text as a message, here or in another channel",
+		Args:        []ArgSpec{{Name: "text", Required: true, Rest: true}},
+		Flags:       []FlagSpec{{Name: "channel", Short: "c", Type: ArgChannel}},
+		KeepMessage: true,
+		Cooldown:    2 * time.Second,
+		Handler:     handleSay,
+	})
+	registerCommand(&Command{Name: "setprefix", Category: "Utilities", Description: "changes prefix", Args: []ArgSpec{{Name: "prefix|off"}}, Cooldown: 5 * time.Second, Handler: rawArgs(handleSetPrefix)})
+	registerCommand(&Command{
+		Name:        "alias",
+		Category:    "Utilities",
+		Description: "Add, remove or list aliases (add|remove|list); separate the steps of a macro with ; and use $1 or $@ for its arguments",
+		Args:        []ArgSpec{{Name: "action", Required: true}, {Name: "name"}, {Name: "command", Rest: true}},
+		Handler:     handleAlias,
+	})
+	registerCommand(&Command{Name: "feature", Category: "Utilities", Description: "List or toggle bot features", Args: []ArgSpec{{Name: "name"}, {Name: "on|off"}}, Handler: rawArgs(handleFeature)})
+
+	registerCommand(&Command{Name: "8ball", Category: "Fun", Description: "Ask the magic 8ball", Args: []ArgSpec{{Name: "question", Required: true, Rest: true}}, Handler: rawArgs(handle8Ball)})
+	registerCommand(&Command{Name: "roll", Category: "Fun", Description: "Roll a die", Args: []ArgSpec{{Name: "sides", Type: ArgInt, Default: "6", Min: 2, Max: 1000000}}, Handler: handleRoll})
+	registerCommand(&Command{Name: "rizz", Category: "Fun", Description: "Get a random pickup line", Handler: rawArgs(handleRizz)})
+	registerCommand(&Command{Name: "femboy", Category: "Fun", Description: "femboy percentage of user", Args: []ArgSpec{{Name: "user", Type: ArgUser}}, Handler: handleFemboy})
+	registerCommand(&Command{Name: "quote", Category: "Fun", Description: "Get a random quote", Cooldown: 3 * time.Second, Handler: noArgs(handleQuote)})
+	registerCommand(&Command{Name: "joke", Category: "Fun", Description: "Get a random joke", Cooldown: 3 * time.Second, Handler: noArgs(handleJoke)})
+	registerCommand(&Command{Name: "urban", Category: "Fun", Description: "Look up a term on Urban Dictionary", Args: []ArgSpec{{Name: "term", Required: true, Rest: true}}, Cooldown: 3 * time.Second, Handler: rawArgs(handleUrban)})
+	registerCommand(&Command{Name: "coinflip", Category: "Fun", Description: "Flip a coin", Handler: noArgs(handleCoinFlip)})
+	registerCommand(&Command{Name: "fact", Category: "Fun", Description: "Get a random fact", Cooldown: 3 * time.Second, Handler: noArgs(handleFact)})
+	registerCommand(&Command{Name: "meme", Category: "Fun", Description: "Get a random meme", Cooldown: 3 * time.Second, Handler: noArgs(handleMemePhrase)})
+
+	registerCommand(&Command{Name: "whoami", Category: "Info", Description: "Show your user info", Handler: noArgs(handleUserInfo)})
+	registerCommand(&Command{Name: "avatar", Category: "Info", Description: "Get your avatar URL", Handler: noArgs(handleAvatar)})
+	registerCommand(&Command{Name: "stats", Category: "Info", Description: "Show bot statistics", Handler: noArgs(handleStats)})
+	registerCommand(&Command{Name: "credits", Category: "Info", Description: "Display bot credits", Handler: noArgs(handleCredits)})
+
+	registerCommand(&Command{Name: "psearch", Category: "NSFW", Description: "Search PornHub for videos", Args: []ArgSpec{{Name: "term", Required: true, Rest: true}}, Cooldown: 5 * time.Second, Handler: rawArgs(handlePornhubSearch)})
+	registerCommand(&Command{Name: "tits", Category: "NSFW", Description: "Get a random tits image", Cooldown: 3 * time.Second, Handler: noArgs(handleTits)})
+	registerCommand(&Command{Name: "catgirl", Category: "NSFW", Description: "Get a random catgirl image", Cooldown: 3 * time.Second, Handler: noArgs(handleCatgirl)})
+}
+
+func registerCommand(command *Command) {
+	for _, name := range append([]string{command.Name}, command.Aliases...) {
+		if _, ok := commandMap[name]; ok {
+			panic("command registered twice: " + name)
+		}
+		commandMap[name] = command
+	}
+
+	commandList = append(commandList, command)
+}
+
+// startCooldown starts command's cooldown, or if it's still cooling down from
+// the last run returns how long is left of it.
+func (command *Command) startCooldown() time.Duration {
+	if command.Cooldown <= 0 {
+		return 0
+	}
+
+	cooldownMutex.Lock()
+	defer cooldownMutex.Unlock()
+
+	now := time.Now()
+	if left := lastRun[command].Add(command.Cooldown).Sub(now); left > 0 {
+		return left
+	}
+
+	lastRun[command] = now
+	return 0
+}
+
+func cooldownText(command *Command, wait time.Duration) string {
+	return fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n⏳ %s%s is on cooldown, try again in %v```", currentConfig().Prefix, command.Name, wait.Round(100*time.Millisecond))
+}
+
+// noArgs adapts a handler that doesn't take arguments.
+func noArgs(handler func(Message)) func(Message, *Args) {
+	return func(message Message, _ *Args) {
+		handler(message)
+	}
+}
+
+// rawArgs adapts a handler that reads its arguments as plain words.
+func rawArgs(handler func(Message, []string)) func(Message, *Args) {
+	return func(message Message, args *Args) {
+		handler(message, args.Raw)
+	}
+}
+
+func lookupCommand(name string) (*Command, bool) {
+	command, ok := commandMap[strings.ToLower(name)]
+	return command, ok
+}
+
+func (command *Command) usageLine() string {
+	line := currentConfig().Prefix + command.Name
+	if usage := command.usage(); usage != "" {
+		line += " " + usage
+	}
+	return line
+}
+
+func (command *Command) helpLine() string {
+	return "\u001b[0;32m" + command.usageLine() + "\u001b[0m - " + command.Description + "\n"
+}
+
+func handleHelp(message Message, args *Args) {
+	if args.Has("command") {
+		handleCommandHelp(message, args.String("command"))
+		return
+	}
+
+	helpText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
+		"Commands:\n"
+	for _, command := range commandList {
+		if command.Category == "" {
+			helpText += command.helpLine()
+		}
+	}
+	helpText += "Tip: Type " + currentConfig().Prefix + "help <command> for detailed help on a specific command\n" +
+		"```"
+	reply(message, helpText)
+}
+
+func handleCommandHelp(message Message, name string) {
+	command, ok := lookupCommand(strings.TrimPrefix(name, currentConfig().Prefix))
+	if !ok {
+		reply(message, unknownCommandText(name))
+		return
+	}
+
+	helpText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
+		"\u001b[0;32m" + command.usageLine() + "\u001b[0m\n" +
+		command.Description + "\n"
+	if command.Category != "" {
+		helpText += "\nCategory: " + command.Category
+	}
+	if len(command.Aliases) > 0 {
+		helpText += "\nAliases: " + strings.Join(command.Aliases, ", ")
+	}
+	helpText += "```"
+	reply(message, helpText)
+}
+
+func handleCategories(message Message) {
+	categoriesText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
+		"Command Categories:\n\n"
+	for _, category := range categories {
+		categoriesText += "\u001b[0;33m" + category.name + "\u001b[0m - " + category.description + "\n"
+	}
+	categoriesText += "\nUse " + currentConfig().Prefix + "<category> to see commands in each category\n" +
+		"```"
+	reply(message, categoriesText)
+}
+
+func handleCategory(message Message, category commandCategory) {
+	categoryText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
+		"\u001b[0;33m" + category.name + " Commands:\u001b[0m\n\n"
+	for _, command := range commandList {
+		if command.Category == category.name {
+			categoryText += command.helpLine()
+		}
+	}
+	categoryText += "```"
+	reply(message, categoryText)
+}
+
+// sendUsageError tells the user what was wrong with how they ran a command.
+func sendUsageError(message Message, err error) {
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ %s```", err))
+}
+
+func unknownCommandText(name string) string {
+	text := fmt.Sprintf("Unknown command: `%s`.", name)
+	if suggestion := suggestCommand(name); suggestion != "" {
+		text += fmt.Sprintf(" Did you mean %s%s?", currentConfig().Prefix, suggestion)
+	}
+	text += fmt.Sprintf(" Type %shelp for a list of commands.", currentConfig().Prefix)
+
+	return "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" + text + "```"
+}
+
+// suggestCommand returns the command name or alias closest to name, or "" if
+// none is close enough to be a likely typo.
+func suggestCommand(name string) string {
+	name = strings.ToLower(name)
+
+	names := make([]string, 0, len(commandMap))
+	for candidate := range commandMap {
+		names = append(names, candidate)
+	}
+	sort.Strings(names) // Break ties the same way every time.
+
+	// Allow one typo in short names and two in longer ones.
+	maxDistance := 1
+	if len(name) > 4 {
+		maxDistance = 2
+	}
+
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range names {
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and swaps of neighbouring characters it takes to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// d[i][j] is the distance between the first i runes of a and the first j
+	// of b.
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
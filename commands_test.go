@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"ping", "ping", 0},
+		{"pign", "ping", 1},
+		{"weathr", "weather", 1},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	tests := map[string]string{
+		"weathr":   "weather",
+		"PIGN":     "ping",
+		"claer":    "clear",
+		"stats":    "stats",
+		"xyzzy":    "",
+		"cloneser": "",
+	}
+
+	for name, want := range tests {
+		if got := suggestCommand(name); got != want {
+			t.Errorf("suggestCommand(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCommandsAreCategorised(t *testing.T) {
+	known := make(map[string]bool)
+	for _, category := range categories {
+		known[category.name] = true
+	}
+
+	for _, command := range commandList {
+		if command.Category != "" && !known[command.Category] {
+			t.Errorf("%s is in unknown category %q", command.Name, command.Category)
+		}
+		if command.Description == "" || command.Handler == nil {
+			t.Errorf("%s is missing a description or handler", command.Name)
+		}
+	}
+}
+
+func TestAutoPressureArgs(t *testing.T) {
+	command, _ := lookupCommand("ap")
+
+	args, err := parseArgs(command, "<@!123456789012345678> --for 5m")
+	if err != nil || args.String("user") != "123456789012345678" || args.Duration("for") != 5*time.Minute {
+		t.Errorf("got %q for %v, %v", args.String("user"), args.Duration("for"), err)
+	}
+
+	args, err = parseArgs(command, "--stop")
+	if err != nil || !args.Bool("stop") || args.Has("user") {
+		t.Errorf("got stop %v, user %q, %v", args.Bool("stop"), args.String("user"), err)
+	}
+
+	if _, err := parseArgs(command, "bob"); err == nil {
+		t.Error("a name that isn't a mention or ID didn't fail")
+	}
+}
+
+func TestCommandCooldown(t *testing.T) {
+	command := &Command{Name: "slow", Cooldown: 50 * time.Millisecond}
+
+	if wait := command.startCooldown(); wait != 0 {
+		t.Fatalf("first run waits %v", wait)
+	}
+	if wait := command.startCooldown(); wait <= 0 || wait > 50*time.Millisecond {
+		t.Errorf("second run waits %v, want up to 50ms", wait)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if wait := command.startCooldown(); wait != 0 {
+		t.Errorf("run after the cooldown waits %v", wait)
+	}
+
+	if wait := (&Command{Name: "fast"}).startCooldown(); wait != 0 {
+		t.Errorf("command without a cooldown waits %v", wait)
+	}
+}
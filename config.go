@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// configPath is where the config file is read from, unless --config or
+	// RUNE_CONFIG says otherwise.
+	configPath = "config.json"
+
+	// configVersion is the version of the config layout this build reads and
+	// writes. Files from older versions are migrated when they're loaded.
+	configVersion = 1
+)
+
+// RPCConfig is the rich presence shown by the rpc client.
+type RPCConfig struct {
+	Enabled       bool   `json:"enabled"`
+	ApplicationID string `json:"application_id"`
+	State         string `json:"state"`
+	Details       string `json:"details"`
+	LargeImage    string `json:"large_image"`
+	LargeText     string `json:"large_text"`
+}
+
+// ConfigError is a problem with one key of the config file.
+type ConfigError struct {
+	Key     string // Key is the path to the key, like rpc.enabled, or "" for a problem with the file as a whole.
+	Problem string
+}
+
+func (err *ConfigError) Error() string {
+	if err.Key == "" {
+		return err.Problem
+	}
+	return fmt.Sprintf("%s: %s", err.Key, err.Problem)
+}
+
+// configMigrations[i] moves a config from version i to version i+1.
+var configMigrations = []func(raw map[string]json.RawMessage) error{
+	migrateConfigV0,
+}
+
+// migrateConfigV0 moves the unversioned layouts to version 1. The bot used to
+// read the owner from OwnerID, and the rpc client from owner_id as a number.
+func migrateConfigV0(raw map[string]json.RawMessage) error {
+	if ownerID, ok := raw["OwnerID"]; ok {
+		if _, ok := raw["owner_id"]; !ok {
+			raw["owner_id"] = ownerID
+		}
+		delete(raw, "OwnerID")
+	}
+
+	if ownerID, ok := raw["owner_id"]; ok {
+		var number json.Number
+		if json.Unmarshal(ownerID, &number) == nil {
+			data, err := json.Marshal(number.String())
+			if err != nil {
+				return err
+			}
+			raw["owner_id"] = data
+		}
+	}
+
+	return nil
+}
+
+// loadConfig reads the config file into the config store, exiting the
+// process if it is unusable. A config from an older version is migrated, and
+// the original is kept next to it. Without a file, the bot runs on the
+// overrides if they're enough, and otherwise writes a template to fill in.
+func loadConfig() {
+	path := configStore.Path()
+
+	configFile, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if errs := validateConfig(configStore.Layered(defaultConfig())); len(errs) == 0 {
+			configStore.Replace(defaultConfig())
+			fmt.Printf("There's no %s, running on the settings from the environment and command line\n", path)
+			return
+		}
+
+		err := configStore.Update(func(cfg *Config) error {
+			*cfg = defaultConfig()
+			cfg.Token = "YOUR_TOKEN_HERE"
+			cfg.GeminiAPIKey = "YOUR_GEMINI_API_KEY"
+			return nil
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created a default config file. Please edit %s with your token and restart.\n", path)
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Println("Error reading config file:", err)
+		os.Exit(1)
+	}
+
+	cfg, fromVersion, err := parseConfig(configFile)
+	if err != nil {
+		printConfigError(path, err)
+		os.Exit(1)
+	}
+	configStore.Load(configFile, cfg)
+
+	if fromVersion < configVersion {
+		backup := fmt.Sprintf("%s.v%d.bak", path, fromVersion)
+		if err := os.WriteFile(backup, configFile, 0600); err != nil {
+			fmt.Println("Error backing up config before migrating it:", err)
+			os.Exit(1)
+		}
+		if err := configStore.Save(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Migrated %s from version %d to %d, the original is in %s\n", path, fromVersion, configVersion, backup)
+	}
+
+	if key := currentConfig().GeminiAPIKey; key == "" || key == "YOUR_GEMINI_API_KEY" {
+		fmt.Println("Please set your Gemini API key in config.json for the &ai command (this message is shown even if api key is active, gemini became retarted)")
+	}
+}
+
+func init() {
+	configStore.Subscribe(applyConfig)
+}
+
+// applyConfig brings the parts of the bot that keep their own copy of a
+// setting in line with a changed config.
+func applyConfig(change ConfigChange) {
+	if change.New.AutoResponseEnabled != change.Old.AutoResponseEnabled {
+		autoResponderMutex.Lock()
+		autoResponderEnabled = change.New.AutoResponseEnabled
+		autoResponderMutex.Unlock()
+	}
+
+	sendBudget.SetLimit(change.New.SendsPerMinute, change.New.SendBurst)
+
+	for _, f := range features {
+		if enabled := featureEnabled(change.New, f.name); enabled != featureEnabled(change.Old, f.name) {
+			f.setEnabled(bus, enabled)
+		}
+	}
+}
+
+// checkConfig validates the config for --check-config, and returns the
+// process's exit code.
+func checkConfig() int {
+	path := configStore.Path()
+
+	configFile, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Without a file, loadConfig runs on the environment and command
+		// line alone if they're valid, so they're what's checked.
+		if err := errors.Join(validateConfig(configStore.Layered(defaultConfig()))...); err != nil {
+			fmt.Printf("There's no %s, and the settings from the environment and command line aren't valid:\n", path)
+			printConfigProblems(err)
+			return 1
+		}
+		fmt.Printf("There's no %s, but the settings from the environment and command line are valid\n", path)
+		return 0
+	}
+	if err != nil {
+		fmt.Println("Error reading config file:", err)
+		return 1
+	}
+
+	_, fromVersion, err := parseConfig(configFile)
+	if err != nil {
+		printConfigError(path, err)
+		return 1
+	}
+
+	if fromVersion < configVersion {
+		fmt.Printf("%s is valid, and will be migrated from version %d to %d when the bot starts\n", path, fromVersion, configVersion)
+	} else {
+		fmt.Printf("%s is valid\n", path)
+	}
+	return 0
+}
+
+// printConfigError lists the problems in err, which were found in source.
+func printConfigError(source string, err error) {
+	fmt.Printf("%s isn't valid:\n", source)
+	printConfigProblems(err)
+}
+
+// printConfigProblems lists the problems in err, one to a line.
+func printConfigProblems(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Println("  " + line)
+	}
+}
+
+// parseConfig decodes and validates a config file, migrating it from the
+// version it was written in, which is returned too. Settings the file leaves
+// out have their defaults. It's validated with the config store's overrides
+// laid over it, but returned without them. Every problem found is returned,
+// joined, as a *ConfigError.
+func parseConfig(data []byte) (Config, int, error) {
+	cfg := defaultConfig()
+
+	var raw map[string]json.RawMessage
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, &raw); err != nil && !errors.As(err, &typeErr) {
+		return cfg, 0, &ConfigError{Problem: describeJSONError(data, err)}
+	}
+	if raw == nil {
+		return cfg, 0, &ConfigError{Problem: "has to be a JSON object"}
+	}
+
+	version := 0
+	if data, ok := raw["version"]; ok {
+		if err := json.Unmarshal(data, &version); err != nil || version < 0 {
+			return cfg, 0, &ConfigError{"version", "has to be a whole number"}
+		}
+	}
+	if version > configVersion {
+		return cfg, version, &ConfigError{"version", fmt.Sprintf("is %d, but this build only reads up to version %d", version, configVersion)}
+	}
+
+	for v := version; v < configVersion; v++ {
+		if err := configMigrations[v](raw); err != nil {
+			return cfg, version, &ConfigError{Problem: fmt.Sprintf("can't be migrated from version %d: %v", v, err)}
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(configVersion))
+
+	var errs []error
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Report problems in the same order every time.
+
+	fields := configFields()
+	target := reflect.ValueOf(&cfg).Elem()
+	for _, key := range keys {
+		index, ok := fields[key]
+		if !ok {
+			errs = append(errs, &ConfigError{key, "isn't a known setting"})
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw[key]))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(target.Field(index).Addr().Interface()); err != nil {
+			errs = append(errs, fieldError(key, err))
+		}
+	}
+
+	if len(errs) == 0 {
+		errs = validateConfig(configStore.Layered(cfg))
+	}
+
+	return cfg, version, errors.Join(errs...)
+}
+
+// configFields maps the config's JSON keys to the index of their field.
+func configFields() map[string]int {
+	fields := make(map[string]int)
+
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+
+	return fields
+}
+
+// fieldError turns an error decoding key into a *ConfigError for the key, or
+// the key inside it, that was wrong.
+func fieldError(key string, err error) *ConfigError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			key += "." + typeErr.Field
+		}
+		return &ConfigError{key, fmt.Sprintf("has to be %s, not %s", describeJSONType(typeErr.Type), typeErr.Value)}
+	}
+
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &ConfigError{key + "." + strings.Trim(name, `"`), "isn't a known setting"}
+	}
+
+	return &ConfigError{key, err.Error()}
+}
+
+func describeJSONType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Map, reflect.Struct, reflect.Pointer:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	}
+	return t.String()
+}
+
+// describeJSONError says where in data a JSON syntax error is.
+func describeJSONError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err.Error()
+	}
+
+	before := data[:min(int(syntaxErr.Offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	if column > 1 {
+		column-- // Offset is just past the offending character.
+	}
+
+	return fmt.Sprintf("line %d, column %d: %v", line, column, syntaxErr)
+}
+
+// validateConfig checks the values of a decoded config.
+func validateConfig(cfg Config) []error {
+	var errs []error
+
+	if cfg.Token == "" || cfg.Token == "YOUR_TOKEN_HERE" {
+		errs = append(errs, &ConfigError{"token", "isn't set, put your Discord token here"})
+	}
+	if cfg.OwnerID != "" && !isSnowflake(cfg.OwnerID) {
+		errs = append(errs, &ConfigError{"owner_id", fmt.Sprintf("%q isn't a Discord user ID", cfg.OwnerID)})
+	}
+	if strings.IndexFunc(cfg.Prefix, unicode.IsSpace) >= 0 {
+		errs = append(errs, &ConfigError{"prefix", "can't contain spaces"})
+	}
+	if cfg.SendsPerMinute < 0 {
+		errs = append(errs, &ConfigError{"sends_per_minute", "can't be negative"})
+	}
+	if cfg.SendBurst < 0 {
+		errs = append(errs, &ConfigError{"send_burst", "can't be negative"})
+	}
+	if cfg.UIPort < 1 || cfg.UIPort > 65535 {
+		errs = append(errs, &ConfigError{"ui_port", "has to be a port, from 1 to 65535"})
+	}
+
+	names := make([]string, 0, len(cfg.Aliases))
+	for name := range cfg.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateAlias(cfg.Prefix, name, cfg.Aliases[name]); err != nil {
+			errs = append(errs, &ConfigError{"aliases." + name, err.Error()})
+		}
+	}
+
+	features := make([]string, 0, len(cfg.Features))
+	for name := range cfg.Features {
+		features = append(features, name)
+	}
+	sort.Strings(features)
+	for _, name := range features {
+		if findFeature(name) == nil {
+			errs = append(errs, &ConfigError{"features." + name, "isn't a feature"})
+		}
+	}
+
+	if cfg.RPC != nil && cfg.RPC.Enabled && !isSnowflake(cfg.RPC.ApplicationID) {
+		errs = append(errs, &ConfigError{"rpc.application_id", "has to be set to an application ID when rpc is enabled"})
+	}
+
+	return errs
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func configErrors(err error) []string {
+	var problems []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+func TestParseConfigMigratesLegacyLayouts(t *testing.T) {
+	tests := map[string]string{
+		"bot": `{"token": "t", "OwnerID": "123456789012345678", "prefix": "&", "auto_response_enabled": true}`,
+		"rpc": `{"token": "t", "owner_id": 123456789012345678, "prefix": "&", "auto_response_enabled": true,
+			"rpc": {"enabled": true, "application_id": "223456789012345678", "state": "Playing"}}`,
+	}
+
+	for name, data := range tests {
+		cfg, version, err := parseConfig([]byte(data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if version != 0 || cfg.Version != configVersion {
+			t.Errorf("%s: migrated from version %d to %d", name, version, cfg.Version)
+		}
+		if cfg.OwnerID != "123456789012345678" || cfg.Token != "t" || !cfg.AutoResponseEnabled {
+			t.Errorf("%s: got %+v", name, cfg)
+		}
+	}
+
+	cfg, _, _ := parseConfig([]byte(tests["rpc"]))
+	if want := (&RPCConfig{Enabled: true, ApplicationID: "223456789012345678", State: "Playing"}); !reflect.DeepEqual(cfg.RPC, want) {
+		t.Errorf("got rpc %+v, want %+v", cfg.RPC, want)
+	}
+}
+
+func TestParseConfigErrorsPointToTheKey(t *testing.T) {
+	_, _, err := parseConfig([]byte(`{
+		"version": 1,
+		"token": "t",
+		"prefx": "&",
+		"auto_response_enabled": "yes",
+		"send_burst": 1.5,
+		"aliases": {"w": 3},
+		"rpc": {"enabled": "true", "colour": "red"}
+	}`))
+
+	want := []string{
+		`aliases.w: has to be a string, not number`,
+		`auto_response_enabled: has to be true or false, not string`,
+		`prefx: isn't a known setting`,
+		`rpc.enabled: has to be true or false, not string`,
+		`send_burst: has to be a whole number, not number 1.5`,
+	}
+	if got := configErrors(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Errorf("%T isn't a *ConfigError", err)
+	}
+}
+
+func TestParseConfigValidates(t *testing.T) {
+	_, _, err := parseConfig([]byte(`{
+		"version": 1,
+		"token": "YOUR_TOKEN_HERE",
+		"owner_id": "me",
+		"send_burst": -1,
+		"aliases": {"ping": "weather"},
+		"features": {"spam": false, "logger": false, "eggs": true},
+		"rpc": {"enabled": true}
+	}`))
+
+	want := []string{
+		`token: isn't set, put your Discord token here`,
+		`owner_id: "me" isn't a Discord user ID`,
+		`send_burst: can't be negative`,
+		`aliases.ping: &ping is already a command`,
+		`features.eggs: isn't a feature`,
+		`features.spam: isn't a feature`,
+		`rpc.application_id: has to be set to an application ID when rpc is enabled`,
+	}
+	if got := configErrors(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckConfigWithoutAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	saved := configStore
+	configStore = NewConfigStore(path)
+	defer func() { configStore = saved }()
+
+	if code := checkConfig(); code != 1 {
+		t.Errorf("got exit code %d without a token, want 1", code)
+	}
+
+	configStore.SetSource(path, func(cfg *Config) { cfg.Token = "env-token" })
+	if code := checkConfig(); code != 0 {
+		t.Errorf("got exit code %d with a token from the environment, want 0", code)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checking created %s", path)
+	}
+}
+
+func TestParseConfigRejectsBrokenFiles(t *testing.T) {
+	tests := map[string]string{
+		"{\n    \"token\": \"t\",\n    \"auto_response_enabled\": false/true\n}": "line 3, column 35: invalid character '/' after object key:value pair",
+		`{"token": "t"`:                  "line 1, column 13: unexpected end of JSON input",
+		`[]`:                             "has to be a JSON object",
+		`{"version": 2, "token": "t"}`:   "version: is 2, but this build only reads up to version 1",
+		`{"version": "1", "token": "t"}`: "version: has to be a whole number",
+	}
+
+	for data, want := range tests {
+		if _, _, err := parseConfig([]byte(data)); err == nil || err.Error() != want {
+			t.Errorf("%q: got %v, want %s", data, err, want)
+		}
+	}
+}
+
+func TestConfigExample(t *testing.T) {
+	data, err := os.ReadFile("config.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The example leaves the token for the user to fill in, and nothing else.
+	_, version, err := parseConfig(data)
+	if version != configVersion || err == nil || err.Error() != "token: isn't set, put your Discord token here" {
+		t.Errorf("got version %d, %v", version, err)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultUIPort = 8080
+
+// defaultConfig is the config the file's settings are laid over. Settings
+// the file leaves out keep these values.
+func defaultConfig() Config {
+	return Config{
+		Version:        configVersion,
+		Prefix:         "&",
+		SendsPerMinute: defaultSendsPerMinute,
+		SendBurst:      defaultSendBurst,
+		UIPort:         defaultUIPort,
+	}
+}
+
+// ConfigOverrides are settings given in RUNE_* environment variables and on
+// the command line. They're laid over the config file, flags over the
+// environment, and are never saved to it.
+type ConfigOverrides struct {
+	env   map[string]string // By config key.
+	flags map[string]string
+}
+
+// overridableKeys returns the config keys that can be overridden, which is
+// all of them but version.
+func overridableKeys() []string {
+	var keys []string
+	for key := range configFields() {
+		if key != "version" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envName is the environment variable that overrides key, like
+// RUNE_OWNER_ID for owner_id.
+func envName(key string) string {
+	return "RUNE_" + strings.ToUpper(key)
+}
+
+// flagName is the command-line flag that overrides key, like --owner-id for
+// owner_id.
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// readEnvOverrides reads the RUNE_* variables set in the environment. Every
+// one that doesn't hold a valid value is returned, joined, as a
+// *ConfigError.
+func readEnvOverrides(overrides *ConfigOverrides) error {
+	var errs []error
+
+	overrides.env = make(map[string]string)
+	for _, key := range overridableKeys() {
+		value, ok := os.LookupEnv(envName(key))
+		if !ok {
+			continue
+		}
+
+		var scratch Config
+		if err := setConfigValue(&scratch, key, value); err != nil {
+			errs = append(errs, &ConfigError{envName(key), err.Error()})
+			continue
+		}
+		overrides.env[key] = value
+	}
+
+	return errors.Join(errs...)
+}
+
+// configFlag is the command-line flag for one setting.
+type configFlag struct {
+	key    string
+	isBool bool
+	values map[string]string
+}
+
+func (f *configFlag) String() string {
+	return ""
+}
+
+func (f *configFlag) Set(value string) error {
+	var scratch Config
+	if err := setConfigValue(&scratch, f.key, value); err != nil {
+		return err
+	}
+	f.values[f.key] = value
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// secretFlag stands in for a secret's flag, so that it's listed in the usage
+// and giving it says where the secret goes instead.
+type secretFlag struct {
+	key string
+}
+
+func (f secretFlag) String() string {
+	return ""
+}
+
+func (f secretFlag) Set(string) error {
+	return fmt.Errorf("%s can't be given on the command line; set %s or put it in the secrets file", f.key, envName(f.key))
+}
+
+// registerConfigFlags adds a flag to flags for every setting, which fills in
+// overrides when the flags are parsed. A command line can be read by anyone
+// who lists processes and ends up in shell history, so secrets are only taken
+// from the environment and the secrets file: their flags just refuse, naming
+// the variable to use.
+func registerConfigFlags(flags *flag.FlagSet, overrides *ConfigOverrides) {
+	overrides.flags = make(map[string]string)
+
+	secret := make(map[string]bool)
+	for _, key := range secretKeys() {
+		secret[key] = true
+	}
+
+	t := reflect.TypeOf(Config{})
+	for _, key := range overridableKeys() {
+		if secret[key] {
+			flags.Var(secretFlag{key}, flagName(key),
+				fmt.Sprintf("not taken as a flag; set %s or use the secrets file", envName(key)))
+			continue
+		}
+
+		field := t.Field(configFields()[key])
+		flags.Var(&configFlag{key, field.Type.Kind() == reflect.Bool, overrides.flags}, flagName(key),
+			fmt.Sprintf("override %s in the config (also %s)", key, envName(key)))
+	}
+}
+
+// apply lays the overrides over cfg.
+func (overrides ConfigOverrides) apply(cfg *Config) {
+	for _, layer := range []map[string]string{overrides.env, overrides.flags} {
+		for key, value := range layer {
+			// The values were checked when they were read.
+			setConfigValue(cfg, key, value)
+		}
+	}
+}
+
+// keys returns the keys that are overridden, for telling the user which
+// settings the file doesn't control.
+func (overrides ConfigOverrides) keys() []string {
+	var keys []string
+	for _, key := range overridableKeys() {
+		_, inEnv := overrides.env[key]
+		_, inFlags := overrides.flags[key]
+		if inEnv || inFlags {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// setConfigValue sets key in cfg from value as it's written in an
+// environment variable or flag. Strings, numbers and true or false are
+// written as they are, and lists and objects like rpc as JSON.
+func setConfigValue(cfg *Config, key, value string) error {
+	index, ok := configFields()[key]
+	if !ok {
+		return fmt.Errorf("%s isn't a known setting", key)
+	}
+	field := reflect.ValueOf(cfg).Elem().Field(index)
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("has to be true or false")
+		}
+		field.SetBool(b)
+
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("has to be a whole number")
+		}
+		field.SetInt(int64(n))
+
+	default:
+		decoded := reflect.New(field.Type())
+		decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(decoded.Interface()); err != nil {
+			return fmt.Errorf("has to be %s, written as JSON", describeJSONType(field.Type()))
+		}
+		field.Set(decoded.Elem())
+	}
+
+	return nil
+}
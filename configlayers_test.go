@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetConfigValue(t *testing.T) {
+	var cfg Config
+	values := map[string]string{
+		"prefix":                "!",
+		"auto_response_enabled": "true",
+		"send_burst":            "5",
+		"rpc":                   `{"enabled": true, "application_id": "223456789012345678"}`,
+	}
+	for key, value := range values {
+		if err := setConfigValue(&cfg, key, value); err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+	}
+
+	want := Config{
+		Prefix:              "!",
+		AutoResponseEnabled: true,
+		SendBurst:           5,
+		RPC:                 &RPCConfig{Enabled: true, ApplicationID: "223456789012345678"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	errs := map[string]string{
+		"auto_response_enabled": "has to be true or false",
+		"send_burst":            "has to be a whole number",
+		"rpc":                   "has to be an object, written as JSON",
+		"aliases":               "has to be an object, written as JSON",
+	}
+	for key, want := range errs {
+		if err := setConfigValue(&cfg, key, "nope"); err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %s", key, err, want)
+		}
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	t.Setenv("RUNE_PREFIX", "?")
+	t.Setenv("RUNE_TOKEN", "env-token")
+
+	var overrides ConfigOverrides
+	flags := flag.NewFlagSet("selfbot", flag.ContinueOnError)
+	registerConfigFlags(flags, &overrides)
+	if err := flags.Parse([]string{"--prefix", "!", "--auto-response-enabled", "--ui-port", "9000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := readEnvOverrides(&overrides); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig()
+	cfg.Token = "file-token"
+	overrides.apply(&cfg)
+
+	// Flags beat the environment, which beats the file.
+	if cfg.Prefix != "!" || cfg.Token != "env-token" || !cfg.AutoResponseEnabled || cfg.UIPort != 9000 {
+		t.Errorf("got %+v", cfg)
+	}
+
+	if keys, want := overrides.keys(), []string{"auto_response_enabled", "prefix", "token", "ui_port"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got overridden keys %v, want %v", keys, want)
+	}
+
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse([]string{"--send-burst", "lots"}); err == nil {
+		t.Error("bad flag value was accepted")
+	}
+
+	// Secrets can't be given on the command line.
+	for _, key := range secretKeys() {
+		err := flags.Parse([]string{"--" + flagName(key), "secret"})
+		if err == nil || !strings.Contains(err.Error(), envName(key)) {
+			t.Errorf("--%s: got %v", flagName(key), err)
+		}
+	}
+	if _, ok := overrides.flags["token"]; ok {
+		t.Error("--token set the token")
+	}
+}
+
+func TestReadEnvOverridesErrors(t *testing.T) {
+	t.Setenv("RUNE_SEND_BURST", "lots")
+	t.Setenv("RUNE_GATEWAY_COMPRESS", "maybe")
+
+	var overrides ConfigOverrides
+	err := readEnvOverrides(&overrides)
+
+	want := []string{
+		"RUNE_GATEWAY_COMPRESS: has to be true or false",
+		"RUNE_SEND_BURST: has to be a whole number",
+	}
+	if got := configErrors(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestOverridesAreNotSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	saved := configStore
+	configStore = NewConfigStore(path)
+	defer func() { configStore = saved }()
+
+	configStore.SetSource(path, func(cfg *Config) { cfg.Token = "env-token" })
+
+	// The file doesn't need the token when it's given another way.
+	cfg, _, err := parseConfig([]byte(`{"version": 1, "prefix": "&"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configStore.Replace(cfg)
+
+	if err := configStore.Update(func(cfg *Config) error { cfg.Prefix = "!"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := currentConfig(); cfg.Token != "env-token" || cfg.Prefix != "!" {
+		t.Errorf("got %+v", cfg)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Token != "" || file.Prefix != "!" {
+		t.Errorf("saved %+v", file)
+	}
+}
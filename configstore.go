@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// configStore holds the running config. Everything that changes the config
+// goes through it.
+var configStore = NewConfigStore(configPath)
+
+// currentConfig returns the config as it is now. The copy is the caller's, and
+// won't change under it.
+func currentConfig() Config {
+	return configStore.Get()
+}
+
+// ConfigChange is passed to a ConfigStore's subscribers when its config
+// changes.
+type ConfigChange struct {
+	Old, New Config
+}
+
+// ConfigStore keeps a config and the file it's saved in in step. Updates are
+// serialized, and only take effect once they're safely on disk: each is
+// written to a temporary file that's synced and then renamed over the old
+// one, so a crash never leaves a half-written config behind.
+//
+// The config in use is the file's with the store's overrides laid over it.
+// Updates change the file's config, so overrides are never saved.
+type ConfigStore struct {
+	path     string
+	override func(cfg *Config)
+
+	updateMu sync.Mutex // Held for the whole of an update, so updates don't interleave.
+
+	mu      sync.RWMutex
+	file    Config            // As it's saved in the file.
+	current Config            // With the overrides.
+	sum     [sha256.Size]byte // Of the file as the store last wrote or loaded it.
+
+	subsMu sync.Mutex
+	subs   []configSubscription // In the order they subscribed.
+	nextID int
+}
+
+type configSubscription struct {
+	id      int
+	handler func(ConfigChange)
+}
+
+func NewConfigStore(path string) *ConfigStore {
+	return &ConfigStore{path: path}
+}
+
+// SetSource points the store at the config file at path, and sets the
+// overrides laid over it. It has to be called before the config is loaded.
+func (store *ConfigStore) SetSource(path string, override func(cfg *Config)) {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	store.path = path
+	store.override = override
+}
+
+// Path returns the path of the config file.
+func (store *ConfigStore) Path() string {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	return store.path
+}
+
+func (store *ConfigStore) Get() Config {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.current
+}
+
+// Update calls change with a copy of the file's config, saves the result and
+// makes it current. If change returns an error or the config can't be saved,
+// the config is left as it was and the error is returned.
+func (store *ConfigStore) Update(change func(cfg *Config) error) error {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	store.mu.RLock()
+	cfg := cloneConfig(store.file)
+	store.mu.RUnlock()
+
+	if err := change(&cfg); err != nil {
+		return err
+	}
+
+	data, err := store.save(cfg)
+	if err != nil {
+		return err
+	}
+
+	store.swap(cfg, sha256.Sum256(data))
+	return nil
+}
+
+// Save writes the config to the file as it is.
+func (store *ConfigStore) Save() error {
+	return store.Update(func(*Config) error { return nil })
+}
+
+// Replace makes cfg the file's config without saving it.
+func (store *ConfigStore) Replace(cfg Config) {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	store.mu.RLock()
+	sum := store.sum
+	store.mu.RUnlock()
+
+	store.swap(cloneConfig(cfg), sum)
+}
+
+// Load makes cfg, parsed from data read from the file, current. Until the
+// file changes again, LoadFile skips it.
+func (store *ConfigStore) Load(data []byte, cfg Config) {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	store.swap(cloneConfig(cfg), sha256.Sum256(data))
+}
+
+// LoadFile reads the file and, if it isn't the file as the store last wrote
+// or loaded it, makes the config parse returns for it current. It reports the
+// change, and whether there was one. Updates wait for the whole of it, so none
+// can be saved between the read and the load and then undone by the older
+// file.
+func (store *ConfigStore) LoadFile(parse func(data []byte) (Config, error)) (ConfigChange, bool, error) {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		return ConfigChange{}, false, err
+	}
+
+	store.mu.RLock()
+	old, sum := store.current, store.sum
+	store.mu.RUnlock()
+
+	if sha256.Sum256(data) == sum {
+		return ConfigChange{}, false, nil
+	}
+
+	cfg, err := parse(data)
+	if err != nil {
+		return ConfigChange{}, false, err
+	}
+
+	store.swap(cloneConfig(cfg), sha256.Sum256(data))
+	return ConfigChange{Old: old, New: store.Get()}, true, nil
+}
+
+// Layered returns cfg with the store's overrides laid over it.
+func (store *ConfigStore) Layered(cfg Config) Config {
+	cfg = cloneConfig(cfg)
+	if store.override != nil {
+		store.override(&cfg)
+	}
+	return cfg
+}
+
+// swap makes file the file's config, saved with the given sum. The update
+// lock must be held.
+func (store *ConfigStore) swap(file Config, sum [sha256.Size]byte) {
+	cfg := store.Layered(file)
+
+	store.mu.Lock()
+	old := store.current
+	store.file = file
+	store.current = cfg
+	store.sum = sum
+	store.mu.Unlock()
+
+	store.notify(ConfigChange{Old: old, New: cfg})
+}
+
+// Subscribe calls handler after every change to the config, in the order
+// they're made. handler can't update the config itself. The returned function
+// unsubscribes.
+func (store *ConfigStore) Subscribe(handler func(ConfigChange)) func() {
+	store.subsMu.Lock()
+	defer store.subsMu.Unlock()
+
+	id := store.nextID
+	store.nextID++
+	store.subs = append(store.subs, configSubscription{id, handler})
+
+	return func() {
+		store.subsMu.Lock()
+		defer store.subsMu.Unlock()
+
+		store.subs = slices.DeleteFunc(store.subs, func(sub configSubscription) bool { return sub.id == id })
+	}
+}
+
+func (store *ConfigStore) notify(change ConfigChange) {
+	store.subsMu.Lock()
+	subs := slices.Clone(store.subs)
+	store.subsMu.Unlock()
+
+	// Each subscriber gets its own copy, so none can change what another
+	// sees.
+	for _, sub := range subs {
+		sub.handler(ConfigChange{Old: cloneConfig(change.Old), New: cloneConfig(change.New)})
+	}
+}
+
+// save writes cfg to the file and returns what it wrote.
+func (store *ConfigStore) save(cfg Config) ([]byte, error) {
+	data, err := json.MarshalIndent(cfg, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling config: %w", err)
+	}
+
+	if err := writeFileAtomic(store.path, data, 0600); err != nil {
+		return nil, fmt.Errorf("error writing config file: %w", err)
+	}
+
+	return data, nil
+}
+
+// cloneConfig copies cfg deeply enough that changing the copy doesn't change
+// cfg.
+func cloneConfig(cfg Config) Config {
+	cfg.Aliases = maps.Clone(cfg.Aliases)
+	cfg.Features = maps.Clone(cfg.Features)
+	if cfg.RPC != nil {
+		rpc := *cfg.RPC
+		cfg.RPC = &rpc
+	}
+	return cfg
+}
+
+// writeFileAtomic replaces path with data. The data is written and synced to a
+// temporary file in the same directory, which is then renamed over path, so
+// readers see either the old file or the new one and never part of it.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Sync the directory too, so the rename survives a crash. Not every
+	// platform can, so this is best effort.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigStoreUpdate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	store := NewConfigStore(path)
+
+	var changes []ConfigChange
+	unsubscribe := store.Subscribe(func(change ConfigChange) {
+		changes = append(changes, change)
+	})
+
+	err := store.Update(func(cfg *Config) error {
+		cfg.Prefix = "!"
+		cfg.Aliases = map[string]string{"hi": "say hi"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("config file has permissions %v, want 0600", perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Prefix != "!" || saved.Aliases["hi"] != "say hi" {
+		t.Errorf("saved %+v", saved)
+	}
+
+	if len(changes) != 1 || changes[0].Old.Prefix != "" || changes[0].New.Prefix != "!" {
+		t.Errorf("subscriber saw %+v", changes)
+	}
+
+	unsubscribe()
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("unsubscribed handler was called %d more times", len(changes)-1)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("left files behind: %v", entries)
+	}
+}
+
+func TestConfigStoreFailedUpdate(t *testing.T) {
+	store := NewConfigStore(filepath.Join(t.TempDir(), "config.json"))
+	store.Replace(Config{Prefix: "&", Aliases: map[string]string{"hi": "say hi"}})
+
+	errRejected := errors.New("rejected")
+	err := store.Update(func(cfg *Config) error {
+		cfg.Prefix = "!"
+		cfg.Aliases["hi"] = "say bye"
+		return errRejected
+	})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("got error %v", err)
+	}
+	if cfg := store.Get(); cfg.Prefix != "&" || cfg.Aliases["hi"] != "say hi" {
+		t.Errorf("rejected update changed the config to %+v", cfg)
+	}
+
+	// A store whose file can't be written keeps its config as it was.
+	broken := NewConfigStore(filepath.Join(t.TempDir(), "missing", "config.json"))
+	broken.Replace(Config{Prefix: "&"})
+	if err := broken.Update(func(cfg *Config) error { cfg.Prefix = "!"; return nil }); err == nil {
+		t.Fatal("saving into a missing directory succeeded")
+	}
+	if prefix := broken.Get().Prefix; prefix != "&" {
+		t.Errorf("unsaved update changed the prefix to %q", prefix)
+	}
+}
+
+func TestConfigStoreLoadFileHoldsOffUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	store := NewConfigStore(path)
+	if err := store.Update(func(cfg *Config) error { cfg.Prefix = "&"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store's own save isn't loaded again.
+	if _, changed, err := store.LoadFile(func([]byte) (Config, error) {
+		t.Error("the store's own save was parsed")
+		return Config{}, nil
+	}); changed || err != nil {
+		t.Errorf("got changed %v, %v", changed, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"prefix": "?"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := make(chan error)
+	change, changed, err := store.LoadFile(func(data []byte) (Config, error) {
+		go func() {
+			updated <- store.Update(func(cfg *Config) error { cfg.Prefix = "!"; return nil })
+		}()
+
+		select {
+		case <-updated:
+			t.Error("an update ran between reading the file and loading it")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		var cfg Config
+		err := json.Unmarshal(data, &cfg)
+		return cfg, err
+	})
+	if err != nil || !changed || change.Old.Prefix != "&" || change.New.Prefix != "?" {
+		t.Fatalf("got %+v, %v, %v", change, changed, err)
+	}
+
+	// The update came after the load, so it's what's left, in the store and
+	// in the file.
+	if err := <-updated; err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	json.Unmarshal(data, &saved)
+	if prefix := store.Get().Prefix; prefix != "!" || saved.Prefix != "!" {
+		t.Errorf("got prefix %q, saved %q", prefix, saved.Prefix)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"time"
+)
+
+const (
+	// configSettleDelay is how long the watcher waits after a change to the
+	// config file before reading it, since editors often save in several
+	// writes.
+	configSettleDelay = 250 * time.Millisecond
+
+	// configPollInterval is how often the file is checked where it can't be
+	// watched.
+	configPollInterval = 2 * time.Second
+)
+
+// watchConfig reloads the config file at path whenever it's edited, for as
+// long as the bot runs.
+func watchConfig(path string) {
+	changes, _, err := watchFile(path)
+	if err != nil {
+		fmt.Printf("Can't watch %s (%v), checking it for changes every %s instead\n", path, err, configPollInterval)
+		changes = pollFile(path, configPollInterval)
+	}
+
+	go reloadOnChanges(path, changes, configPollInterval, nil)
+}
+
+// reloadOnChanges reloads the config file at path on every change sent on
+// changes, until done is closed. If changes is closed, because watching the
+// file failed, it checks the file every pollInterval instead.
+func reloadOnChanges(path string, changes <-chan struct{}, pollInterval time.Duration, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case _, ok := <-changes:
+			if !ok {
+				fmt.Printf("Checking %s for changes every %s instead\n", path, pollInterval)
+				changes = pollFile(path, pollInterval)
+				continue
+			}
+		}
+
+		time.Sleep(configSettleDelay)
+		select {
+		case <-changes:
+		default:
+		}
+
+		reloadConfig(path)
+	}
+}
+
+// pollFile sends on the returned channel whenever the size or modification
+// time of the file at path changes.
+func pollFile(path string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	stat := func() (int64, time.Time) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return -1, time.Time{}
+		}
+		return info.Size(), info.ModTime()
+	}
+
+	go func() {
+		size, modTime := stat()
+		for range time.Tick(interval) {
+			newSize, newModTime := stat()
+			if newSize == size && newModTime.Equal(modTime) {
+				continue
+			}
+			size, modTime = newSize, newModTime
+
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changes
+}
+
+// reloadConfig reads the config file at path and, if it's changed and is
+// valid, makes it current. A config that isn't valid is reported and the
+// running one is kept.
+func reloadConfig(path string) {
+	// The bot's own saves, and edits that didn't change anything, are skipped.
+	change, changed, err := configStore.LoadFile(func(data []byte) (Config, error) {
+		cfg, _, err := parseConfig(data)
+		return cfg, err
+	})
+
+	var pathErr *fs.PathError
+	switch {
+	case os.IsNotExist(err):
+		return
+	case errors.As(err, &pathErr):
+		fmt.Println("Error reading config file:", err)
+		return
+	case err != nil:
+		printConfigError(path, err)
+		fmt.Println("Kept the running config")
+		return
+	case !changed:
+		return
+	}
+
+	fmt.Printf("Reloaded %s\n", path)
+
+	for _, note := range restartNotes(change.Old, change.New) {
+		fmt.Println(note)
+	}
+}
+
+// restartNotes describes the changes between old and cfg to settings that
+// are only read when the bot or the rpc client starts or connects. Every
+// other setting applies as soon as it's reloaded.
+func restartNotes(old, cfg Config) []string {
+	var notes []string
+
+	if cfg.Token != old.Token {
+		notes = append(notes, "token changed, restart the bot to log in with it")
+	}
+	if cfg.GatewayCompress != old.GatewayCompress {
+		notes = append(notes, "gateway_compress changed, and applies the next time the gateway connects")
+	}
+	if cfg.UIPort != old.UIPort {
+		notes = append(notes, "ui_port changed, restart the bot to move the web UI to it")
+	}
+	if !reflect.DeepEqual(cfg.RPC, old.RPC) {
+		notes = append(notes, "rpc changed, restart the rpc client to pick it up")
+	}
+
+	return notes
+}
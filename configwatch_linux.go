@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// watchFile uses inotify to send on the returned channel whenever the file
+// at path is written, created or replaced. It watches the directory rather
+// than the file, since an atomic save replaces the file with a new one. The
+// channel is closed if watching fails, or once the returned io.Closer is
+// closed.
+func watchFile(path string) (<-chan struct{}, io.Closer, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, fmt.Errorf("inotify: %w", err)
+	}
+
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return nil, nil, fmt.Errorf("inotify: watching %s: %w", dir, err)
+	}
+
+	// As an *os.File, reads go through the runtime's poller, and closing the
+	// file ends a read that's waiting.
+	file := os.NewFile(uintptr(fd), "inotify")
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer close(changes)
+
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil || n <= 0 {
+				fmt.Println("Stopped watching the config file:", err)
+				file.Close()
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				offset += syscall.SizeofInotifyEvent
+
+				eventName := buf[offset : offset+int(event.Len)]
+				offset += int(event.Len)
+
+				// The name is padded with NULs.
+				if string(bytes.TrimRight(eventName, "\x00")) != name {
+					continue
+				}
+
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changes, file, nil
+}
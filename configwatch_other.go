@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// watchFile isn't supported here, so watchConfig falls back to polling.
+func watchFile(path string) (<-chan struct{}, io.Closer, error) {
+	return nil, nil, errors.New("file events aren't supported on this platform")
+}
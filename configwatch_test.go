@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func waitForChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change wasn't noticed")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	changes, watcher, err := watchFile(path)
+	if err != nil {
+		t.Skip("can't watch files here:", err)
+	}
+	defer watcher.Close()
+
+	// Other files in the directory, like the temporary one an atomic save
+	// writes first, are ignored.
+	if err := os.WriteFile(path+".bak", []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("a change to another file was reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := writeFileAtomic(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, changes)
+}
+
+func TestPollFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	changes := pollFile(path, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, changes)
+}
+
+func TestReloadsFallBackToPolling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	saved := configStore
+	configStore = NewConfigStore(path)
+	defer func() { configStore = saved }()
+
+	if err := configStore.Update(func(cfg *Config) error {
+		*cfg = defaultConfig()
+		cfg.Token = "t"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	configStore.Subscribe(func(ConfigChange) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+
+	changes, watcher, err := watchFile(path)
+	if err != nil {
+		t.Skip("can't watch files here:", err)
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		reloadOnChanges(path, changes, 10*time.Millisecond, done)
+		close(stopped)
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	// The watch failing closes changes, and the file is polled instead.
+	watcher.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(path, []byte(`{"version": 1, "token": "t", "prefix": "!"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, reloaded)
+	if prefix := currentConfig().Prefix; prefix != "!" {
+		t.Errorf("got prefix %q after the reload", prefix)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	saved := configStore
+	configStore = NewConfigStore(path)
+	defer func() { configStore = saved }()
+
+	var changes int
+	configStore.Subscribe(func(ConfigChange) { changes++ })
+
+	if err := configStore.Update(func(cfg *Config) error {
+		*cfg = defaultConfig()
+		cfg.Token = "t"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store's own save isn't reloaded.
+	reloadConfig(path)
+	if changes != 1 {
+		t.Errorf("own save was reloaded")
+	}
+
+	os.WriteFile(path, []byte(`{"version": 1, "token": "t", "prefix": "!"}`), 0600)
+	reloadConfig(path)
+	if prefix := currentConfig().Prefix; changes != 2 || prefix != "!" {
+		t.Errorf("edit wasn't reloaded, prefix is %q", prefix)
+	}
+
+	// An invalid edit leaves the running config alone.
+	os.WriteFile(path, []byte(`{"version": 1, "token": "t", "prefix": "a b"}`), 0600)
+	reloadConfig(path)
+	if prefix := currentConfig().Prefix; changes != 2 || prefix != "!" {
+		t.Errorf("invalid edit was reloaded, prefix is %q", prefix)
+	}
+}
+
+func TestRestartNotes(t *testing.T) {
+	old := Config{Token: "a", Prefix: "&", RPC: &RPCConfig{State: "Playing"}}
+
+	cfg := cloneConfig(old)
+	cfg.Prefix = "!"
+	cfg.AutoResponsePhrase = "brb"
+	if notes := restartNotes(old, cfg); len(notes) != 0 {
+		t.Errorf("live settings need a restart: %v", notes)
+	}
+
+	cfg.Token = "b"
+	cfg.RPC.State = "Idle"
+	want := []string{
+		"token changed, restart the bot to log in with it",
+		"rpc changed, restart the rpc client to pick it up",
+	}
+	if notes := restartNotes(old, cfg); !reflect.DeepEqual(notes, want) {
+		t.Errorf("got %v, want %v", notes, want)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// DeliveryMode controls how a subscriber is called.
+type DeliveryMode int
+
+const (
+	// DeliverOrdered calls the subscriber on the bus goroutine, one event at
+	// a time and in gateway order. Ordered subscribers must return quickly,
+	// since they hold up every event behind them.
+	DeliverOrdered DeliveryMode = iota
+	// DeliverConcurrent calls the subscriber on a new goroutine per event.
+	DeliverConcurrent
+)
+
+// Subscription is a handler registered on an EventBus.
+type Subscription struct {
+	bus     *EventBus
+	event   string
+	mode    DeliveryMode
+	handler func(Event)
+	active  atomic.Bool
+}
+
+// Unsubscribe removes the subscription from its bus. Events already queued
+// for it are discarded.
+func (sub *Subscription) Unsubscribe() {
+	if !sub.active.Swap(false) {
+		return
+	}
+
+	bus := sub.bus
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	subs := bus.subscribers[sub.event]
+	for i, other := range subs {
+		if other == sub {
+			bus.subscribers[sub.event] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+}
+
+// EventBusStats counts what happened to the events published on a bus.
+type EventBusStats struct {
+	Published uint64 `json:"published"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Panics    uint64 `json:"panics"`
+	Queued    int    `json:"queued"`
+}
+
+// EventBus fans gateway events out to subscribers. Publishing never blocks:
+// events go through a bounded queue, and are dropped and counted when a slow
+// subscriber lets it fill up.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]*Subscription
+
+	queue     chan Event
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	published atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	panics    atomic.Uint64
+}
+
+// NewEventBus creates a bus whose queue holds up to queueSize events, and
+// starts its delivery goroutine.
+func NewEventBus(queueSize int) *EventBus {
+	bus := &EventBus{
+		subscribers: make(map[string][]*Subscription),
+		queue:       make(chan Event, queueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go bus.run()
+
+	return bus
+}
+
+// Subscribe registers handler for every event of type T published on bus.
+func Subscribe[T Event](bus *EventBus, mode DeliveryMode, handler func(*T)) *Subscription {
+	var zero T
+
+	sub := &Subscription{
+		bus:   bus,
+		event: zero.EventName(),
+		mode:  mode,
+		handler: func(event Event) {
+			handler(any(event).(*T))
+		},
+	}
+	sub.active.Store(true)
+
+	bus.mu.Lock()
+	bus.subscribers[sub.event] = append(bus.subscribers[sub.event], sub)
+	bus.mu.Unlock()
+
+	return sub
+}
+
+// Publish queues event for delivery. It reports false if the event was
+// dropped because the queue is full or the bus is closed.
+func (bus *EventBus) Publish(event Event) bool {
+	select {
+	case <-bus.stop:
+		bus.dropped.Add(1)
+		return false
+	default:
+	}
+
+	select {
+	case bus.queue <- event:
+		bus.published.Add(1)
+		return true
+	default:
+		bus.dropped.Add(1)
+		return false
+	}
+}
+
+// Close stops delivery and waits for the bus goroutine to exit. Concurrent
+// subscribers that are still running are not waited for.
+func (bus *EventBus) Close() {
+	bus.closeOnce.Do(func() {
+		close(bus.stop)
+	})
+
+	<-bus.done
+}
+
+// Stats returns a snapshot of the bus counters.
+func (bus *EventBus) Stats() EventBusStats {
+	return EventBusStats{
+		Published: bus.published.Load(),
+		Delivered: bus.delivered.Load(),
+		Dropped:   bus.dropped.Load(),
+		Panics:    bus.panics.Load(),
+		Queued:    len(bus.queue),
+	}
+}
+
+func (bus *EventBus) run() {
+	defer close(bus.done)
+
+	for {
+		select {
+		case <-bus.stop:
+			return
+		case event := <-bus.queue:
+			bus.deliver(event)
+		}
+	}
+}
+
+func (bus *EventBus) deliver(event Event) {
+	bus.mu.RLock()
+	subs := bus.subscribers[event.EventName()]
+	bus.mu.RUnlock()
+
+	for _, sub := range subs {
+		switch sub.mode {
+		case DeliverConcurrent:
+			go bus.call(sub, event)
+		default:
+			bus.call(sub, event)
+		}
+	}
+}
+
+// call runs one subscriber, containing any panic so that a broken feature
+// can't take the bus, or the process, down with it.
+func (bus *EventBus) call(sub *Subscription, event Event) {
+	if !sub.active.Load() {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			bus.panics.Add(1)
+			fmt.Printf("Subscriber for %s panicked: %v\n%s\n", sub.event, r, debug.Stack())
+		}
+	}()
+
+	sub.handler(event)
+	bus.delivered.Add(1)
+}
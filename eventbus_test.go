@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func messageEvent(content string) *MessageCreate {
+	return &MessageCreate{Message{Content: content}}
+}
+
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestEventBusDropsWhenFull(t *testing.T) {
+	bus := NewEventBus(1)
+	defer bus.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		if event.Content == "first" {
+			close(started)
+			<-release
+		}
+	})
+
+	// The first event holds up the bus goroutine, the second fills the
+	// queue, and the third has nowhere to go.
+	bus.Publish(messageEvent("first"))
+	waitFor(t, started, "the first event")
+
+	if !bus.Publish(messageEvent("second")) {
+		t.Fatal("second event was dropped with room in the queue")
+	}
+	if bus.Publish(messageEvent("third")) {
+		t.Fatal("third event was queued on a full queue")
+	}
+
+	stats := bus.Stats()
+	if stats.Published != 2 || stats.Dropped != 1 || stats.Queued != 1 {
+		t.Errorf("got %+v", stats)
+	}
+
+	close(release)
+}
+
+func TestEventBusContainsPanics(t *testing.T) {
+	bus := NewEventBus(8)
+	defer bus.Close()
+
+	var mu sync.Mutex
+	var got []string
+	delivered := make(chan struct{}, 2)
+
+	Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		panic("broken feature")
+	})
+	Subscribe(bus, DeliverConcurrent, func(event *MessageCreate) {
+		panic("broken concurrent feature")
+	})
+	Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		mu.Lock()
+		got = append(got, event.Content)
+		mu.Unlock()
+		delivered <- struct{}{}
+	})
+
+	// The bus keeps delivering after a panic, to the other subscribers and
+	// to later events.
+	bus.Publish(messageEvent("one"))
+	bus.Publish(messageEvent("two"))
+	for range 2 {
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the healthy subscriber wasn't called")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("healthy subscriber got %v", got)
+	}
+
+	// The concurrent subscriber's panics may still be on their way.
+	deadline := time.Now().Add(5 * time.Second)
+	for bus.Stats().Panics < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if panics := bus.Stats().Panics; panics != 4 {
+		t.Errorf("counted %d panics, want 4", panics)
+	}
+}
+
+func TestEventBusOrderedDelivery(t *testing.T) {
+	bus := NewEventBus(256)
+	defer bus.Close()
+
+	const n = 100
+	var got []string
+	done := make(chan struct{})
+	Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		got = append(got, event.Content)
+		if len(got) == n {
+			close(done)
+		}
+	})
+
+	for i := range n {
+		bus.Publish(messageEvent(fmt.Sprint(i)))
+	}
+	waitFor(t, done, "every event")
+
+	for i, content := range got {
+		if content != fmt.Sprint(i) {
+			t.Fatalf("event %d was %s, delivered out of order", i, content)
+		}
+	}
+}
+
+func TestEventBusConcurrentDelivery(t *testing.T) {
+	bus := NewEventBus(8)
+	defer bus.Close()
+
+	// Every call waits for all of them to have started, which only works if
+	// they run at the same time.
+	const n = 4
+	var started sync.WaitGroup
+	started.Add(n)
+	var finished sync.WaitGroup
+	finished.Add(n)
+
+	Subscribe(bus, DeliverConcurrent, func(event *MessageCreate) {
+		started.Done()
+		started.Wait()
+		finished.Done()
+	})
+
+	for i := range n {
+		bus.Publish(messageEvent(fmt.Sprint(i)))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		finished.Wait()
+		close(done)
+	}()
+	waitFor(t, done, "concurrent subscribers")
+}
+
+func TestUnsubscribeDuringDelivery(t *testing.T) {
+	bus := NewEventBus(8)
+	defer bus.Close()
+
+	var later *Subscription
+	var laterCalls int
+	done := make(chan struct{}, 2)
+
+	var first *Subscription
+	first = Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		// Unsubscribing, even itself, from inside a handler doesn't
+		// deadlock, and takes effect for the event being delivered.
+		later.Unsubscribe()
+		first.Unsubscribe()
+	})
+	later = Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		laterCalls++
+	})
+	Subscribe(bus, DeliverOrdered, func(event *MessageCreate) {
+		done <- struct{}{}
+	})
+
+	bus.Publish(messageEvent("one"))
+	bus.Publish(messageEvent("two"))
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("delivery stopped after unsubscribing")
+		}
+	}
+
+	if laterCalls != 0 {
+		t.Errorf("unsubscribed handler was called %d times", laterCalls)
+	}
+}
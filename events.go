@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is implemented by every typed gateway dispatch event. EventName
+// returns the dispatch name ("t" field) the event is decoded from.
+type Event interface {
+	EventName() string
+}
+
+type User struct {
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	GlobalName    string `json:"global_name"`
+	Discriminator string `json:"discriminator"`
+	Avatar        string `json:"avatar"`
+	Bot           bool   `json:"bot"`
+}
+
+type Attachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	URL         string `json:"url"`
+}
+
+type Emoji struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Animated bool   `json:"animated"`
+}
+
+type Channel struct {
+	ID            string `json:"id"`
+	Type          int    `json:"type"`
+	GuildID       string `json:"guild_id"`
+	Name          string `json:"name"`
+	Topic         string `json:"topic"`
+	ParentID      string `json:"parent_id"`
+	LastMessageID string `json:"last_message_id"`
+	Recipients    []User `json:"recipients"`
+}
+
+type Guild struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Icon        string    `json:"icon"`
+	OwnerID     string    `json:"owner_id"`
+	MemberCount int       `json:"member_count"`
+	Unavailable bool      `json:"unavailable"`
+	Channels    []Channel `json:"channels"`
+}
+
+type Activity struct {
+	Name    string `json:"name"`
+	Type    int    `json:"type"`
+	State   string `json:"state"`
+	Details string `json:"details"`
+}
+
+type Ready struct {
+	Version          int     `json:"v"`
+	SessionID        string  `json:"session_id"`
+	ResumeGatewayURL string  `json:"resume_gateway_url"`
+	User             User    `json:"user"`
+	Guilds           []Guild `json:"guilds"`
+}
+
+type Resumed struct{}
+
+type MessageCreate struct {
+	Message
+}
+
+// MessageUpdate carries the edited message. Discord may send a partial
+// message, so any field other than ID and ChannelID can be empty.
+type MessageUpdate struct {
+	Message
+}
+
+type MessageDelete struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+}
+
+type MessageDeleteBulk struct {
+	IDs       []string `json:"ids"`
+	ChannelID string   `json:"channel_id"`
+	GuildID   string   `json:"guild_id"`
+}
+
+type MessageReaction struct {
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+	GuildID   string `json:"guild_id"`
+	Emoji     Emoji  `json:"emoji"`
+}
+
+type MessageReactionAdd struct {
+	MessageReaction
+}
+
+type MessageReactionRemove struct {
+	MessageReaction
+}
+
+type ChannelCreate struct {
+	Channel
+}
+
+type ChannelUpdate struct {
+	Channel
+}
+
+type ChannelDelete struct {
+	Channel
+}
+
+type GuildCreate struct {
+	Guild
+}
+
+// GuildDelete is sent when the account leaves a guild, or with Unavailable
+// set when the guild goes down in an outage.
+type GuildDelete struct {
+	ID          string `json:"id"`
+	Unavailable bool   `json:"unavailable"`
+}
+
+type PresenceUpdate struct {
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	GuildID      string            `json:"guild_id"`
+	Status       string            `json:"status"`
+	Activities   []Activity        `json:"activities"`
+	ClientStatus map[string]string `json:"client_status"`
+}
+
+type TypingStart struct {
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	UserID    string `json:"user_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type UserUpdate struct {
+	User
+}
+
+func (Ready) EventName() string                 { return "READY" }
+func (Resumed) EventName() string               { return "RESUMED" }
+func (MessageCreate) EventName() string         { return "MESSAGE_CREATE" }
+func (MessageUpdate) EventName() string         { return "MESSAGE_UPDATE" }
+func (MessageDelete) EventName() string         { return "MESSAGE_DELETE" }
+func (MessageDeleteBulk) EventName() string     { return "MESSAGE_DELETE_BULK" }
+func (MessageReactionAdd) EventName() string    { return "MESSAGE_REACTION_ADD" }
+func (MessageReactionRemove) EventName() string { return "MESSAGE_REACTION_REMOVE" }
+func (ChannelCreate) EventName() string         { return "CHANNEL_CREATE" }
+func (ChannelUpdate) EventName() string         { return "CHANNEL_UPDATE" }
+func (ChannelDelete) EventName() string         { return "CHANNEL_DELETE" }
+func (GuildCreate) EventName() string           { return "GUILD_CREATE" }
+func (GuildDelete) EventName() string           { return "GUILD_DELETE" }
+func (PresenceUpdate) EventName() string        { return "PRESENCE_UPDATE" }
+func (TypingStart) EventName() string           { return "TYPING_START" }
+func (UserUpdate) EventName() string            { return "USER_UPDATE" }
+
+// eventTypes maps dispatch names to constructors for their typed events.
+var eventTypes = map[string]func() Event{}
+
+func init() {
+	for _, newEvent := range []func() Event{
+		func() Event { return new(Ready) },
+		func() Event { return new(Resumed) },
+		func() Event { return new(MessageCreate) },
+		func() Event { return new(MessageUpdate) },
+		func() Event { return new(MessageDelete) },
+		func() Event { return new(MessageDeleteBulk) },
+		func() Event { return new(MessageReactionAdd) },
+		func() Event { return new(MessageReactionRemove) },
+		func() Event { return new(ChannelCreate) },
+		func() Event { return new(ChannelUpdate) },
+		func() Event { return new(ChannelDelete) },
+		func() Event { return new(GuildCreate) },
+		func() Event { return new(GuildDelete) },
+		func() Event { return new(PresenceUpdate) },
+		func() Event { return new(TypingStart) },
+		func() Event { return new(UserUpdate) },
+	} {
+		eventTypes[newEvent().EventName()] = newEvent
+	}
+}
+
+// decodeEvent decodes a dispatch payload into its typed event. It returns a
+// nil event for dispatch names without a Go type.
+func decodeEvent(name string, data json.RawMessage) (Event, error) {
+	newEvent, ok := eventTypes[name]
+	if !ok {
+		return nil, nil
+	}
+
+	event := newEvent()
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, fmt.Errorf("failed to parse %s data: %w", name, err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feature is a piece of bot behaviour built from event bus subscriptions,
+// which can be switched on and off while the bot is running.
+type feature struct {
+	name        string
+	description string
+	subscribe   func(bus *EventBus) []*Subscription
+
+	mu      sync.Mutex
+	enabled bool
+	subs    []*Subscription
+}
+
+// FeatureState describes a feature for the web UI and the feature command.
+type FeatureState struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// features is filled in init, since the command handlers reachable from it
+// refer back to the registry.
+var features []*feature
+
+func init() {
+	features = []*feature{
+		{
+			name:        "logger",
+			description: "Log incoming messages and count them in the stats",
+			subscribe: func(bus *EventBus) []*Subscription {
+				return []*Subscription{Subscribe(bus, DeliverOrdered, logMessage)}
+			},
+		},
+		{
+			name:        "autoreact",
+			description: "React to your own messages with the auto-react emoji",
+			subscribe: func(bus *EventBus) []*Subscription {
+				return []*Subscription{Subscribe(bus, DeliverConcurrent, autoReact)}
+			},
+		},
+		{
+			name:        "autoresponder",
+			description: "Answer mentions while the auto responder is toggled on",
+			subscribe: func(bus *EventBus) []*Subscription {
+				return []*Subscription{Subscribe(bus, DeliverConcurrent, autoRespond)}
+			},
+		},
+		{
+			name:        "commands",
+			description: "Run prefixed commands sent from the owner account, and re-run them when edited",
+			subscribe: func(bus *EventBus) []*Subscription {
+				return []*Subscription{
+					Subscribe(bus, DeliverConcurrent, runOwnerCommand),
+					Subscribe(bus, DeliverConcurrent, rerunOwnerCommand),
+				}
+			},
+		},
+	}
+}
+
+func (f *feature) setEnabled(bus *EventBus, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.enabled == enabled {
+		return
+	}
+
+	if enabled {
+		f.subs = f.subscribe(bus)
+	} else {
+		for _, sub := range f.subs {
+			sub.Unsubscribe()
+		}
+		f.subs = nil
+	}
+
+	f.enabled = enabled
+}
+
+func (f *feature) state() FeatureState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return FeatureState{
+		Name:        f.name,
+		Description: f.description,
+		Enabled:     f.enabled,
+	}
+}
+
+func findFeature(name string) *feature {
+	for _, f := range features {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// featureEnabled reports whether cfg has the feature name on. Features are on
+// unless the config switches them off.
+func featureEnabled(cfg Config, name string) bool {
+	enabled, ok := cfg.Features[name]
+	return !ok || enabled
+}
+
+// setFeatureEnabled switches a feature on or off and saves that in the
+// config, which applyConfig then follows.
+func setFeatureEnabled(name string, enabled bool) error {
+	if findFeature(name) == nil {
+		return fmt.Errorf("unknown feature: %s", name)
+	}
+
+	return configStore.Update(func(cfg *Config) error {
+		if enabled {
+			delete(cfg.Features, name)
+			return nil
+		}
+
+		if cfg.Features == nil {
+			cfg.Features = make(map[string]bool)
+		}
+		cfg.Features[name] = false
+		return nil
+	})
+}
+
+func featureStates() []FeatureState {
+	states := make([]FeatureState, 0, len(features))
+	for _, f := range features {
+		states = append(states, f.state())
+	}
+	return states
+}
+
+// isFreshMessage filters out messages sent before the bot started, which the
+// gateway can replay after a resume.
+func isFreshMessage(message Message) bool {
+	messageTime, err := time.Parse(time.RFC3339, message.Timestamp)
+	return err == nil && messageTime.After(startTime)
+}
+
+func logMessage(event *MessageCreate) {
+	message := event.Message
+	if !isFreshMessage(message) || message.Author.Bot {
+		return
+	}
+
+	fmt.Printf("Message from %s: %s\n", message.Author.Username, message.Content)
+
+	statsMutex.Lock()
+	messagesLogged++
+	statsMutex.Unlock()
+}
+
+func autoReact(event *MessageCreate) {
+	message := event.Message
+	if !isFreshMessage(message) || message.Author.Bot || message.Author.ID != currentConfig().OwnerID {
+		return
+	}
+
+	if currentConfig().AutoReactEmojiEnabled && currentConfig().AutoReactEmoji != "" {
+		sendReaction(message.ChannelID, message.ID, currentConfig().AutoReactEmoji)
+	}
+}
+
+func autoRespond(event *MessageCreate) {
+	message := event.Message
+	ownerIDStr := currentConfig().OwnerID
+	if !isFreshMessage(message) || message.Author.Bot || message.Author.ID == ownerIDStr {
+		return
+	}
+
+	autoResponderMutex.Lock()
+	enabled := autoResponderEnabled
+	autoResponderMutex.Unlock()
+
+	if !enabled {
+		return
+	}
+
+	selfMentioned := false
+	for _, mention := range message.Mentions {
+		if mention.ID == ownerIDStr {
+			selfMentioned = true
+			break
+		}
+	}
+
+	if !selfMentioned && strings.Contains(message.Content, "<@"+ownerIDStr+">") {
+		selfMentioned = true
+	}
+
+	if selfMentioned {
+		fmt.Printf("Autoresponder triggered by %s\n", message.Author.Username)
+		response := currentConfig().AutoResponsePhrase
+		if response == "" {
+			response = "I'm currently unavailable. Please try again later."
+		}
+		response = strings.ReplaceAll(response, "<user>", message.Author.Username)
+		autoResponse := fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s```", response)
+		// The response answers the message that mentioned us, without
+		// pinging its author back.
+		content, followUps := splitContent(autoResponse)
+		if _, err := postMessage(message.ChannelID, NewMessage(content).ReplyTo(message).NoMentions()); err != nil {
+			return
+		}
+		sendFollowUps(message.ChannelID, followUps)
+	}
+}
+
+func runOwnerCommand(event *MessageCreate) {
+	message := event.Message
+	if !isFreshMessage(message) {
+		return
+	}
+
+	ownerIDStr := currentConfig().OwnerID
+	fmt.Printf("Message author ID: %s, Owner ID: %s\n", message.Author.ID, ownerIDStr)
+
+	if message.Author.ID != ownerIDStr && message.Author.Username != "ndq2" {
+		return
+	}
+
+	fmt.Printf("Owner command detected: %s\n", message.Content)
+	if !strings.HasPrefix(message.Content, currentConfig().Prefix) {
+		return
+	}
+
+	// Trigger typing indicator and simulate huma shi
+	triggerTyping(message.ChannelID)
+
+	// Random delay between 2.0 and 2.5 seconds (removed to make faster)
+	//delay := 2000 + rand.Intn(501) // 2000-2500 ms
+	//time.Sleep(time.Duration(delay) * time.Millisecond)
+
+	handleMessage(message)
+}
+
+// rerunOwnerCommand runs an edited command again, editing its earlier
+// responses in place. Only commands that ran in the last few minutes are
+// re-run, which also keeps out messages edited by anyone else.
+func rerunOwnerCommand(event *MessageUpdate) {
+	message := event.Message
+	if message.Content == "" || !strings.HasPrefix(message.Content, currentConfig().Prefix) || !claimRerun(message) {
+		return
+	}
+
+	fmt.Printf("Re-running edited command: %s\n", message.Content)
+	handleMessage(message)
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// GatewayState describes where the gateway connection is in its lifecycle.
+type GatewayState int32
+
+const (
+	GatewayConnecting GatewayState = iota
+	GatewayIdentifying
+	GatewayReady
+	GatewayResuming
+	GatewayClosed
+)
+
+func (s GatewayState) String() string {
+	switch s {
+	case GatewayConnecting:
+		return "connecting"
+	case GatewayIdentifying:
+		return "identifying"
+	case GatewayReady:
+		return "ready"
+	case GatewayResuming:
+		return "resuming"
+	case GatewayClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	gatewayBackoffBase = time.Second
+	gatewayBackoffMax  = 2 * time.Minute
+)
+
+var (
+	errGatewayClosed    = errors.New("gateway closed")
+	errReconnectRequest = errors.New("server requested reconnect")
+	errInvalidSession   = errors.New("invalid session")
+)
+
+// Gateway supervises the connection to the Discord gateway. It owns the
+// websocket, the heartbeat goroutine and the read loop, and reconnects with
+// jittered exponential backoff whenever the session drops.
+type Gateway struct {
+	dispatch func(payload WSPayload)
+
+	mu        sync.Mutex
+	conn      *websocket.Conn // The current session's, or nil between sessions.
+	started   bool            // Whether Start launched the supervisor.
+	state     GatewayState
+	sessionID string
+	resumeURL string
+	sequence  int
+
+	heartbeatSent  time.Time
+	heartbeatAcked bool
+	latency        time.Duration
+
+	writeMu sync.Mutex // gorilla/websocket allows only one concurrent writer.
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewGateway creates a gateway that hands every dispatch payload to dispatch.
+// The connection isn't opened until Start is called.
+func NewGateway(dispatch func(payload WSPayload)) *Gateway {
+	return &Gateway{
+		dispatch: dispatch,
+		state:    GatewayClosed,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the connection supervisor in the background. It does nothing
+// if the gateway has already been started or closed.
+func (g *Gateway) Start() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.started || g.stopped() {
+		return
+	}
+	g.started = true
+	g.state = GatewayConnecting
+
+	go g.run()
+}
+
+// Close stops the supervisor, closes the connection with a normal closure
+// and waits for every gateway goroutine to exit. A gateway that was never
+// started is just marked closed.
+func (g *Gateway) Close() {
+	g.closeOnce.Do(func() {
+		close(g.stop)
+
+		g.mu.Lock()
+		conn, started := g.conn, g.started
+		g.mu.Unlock()
+
+		if conn != nil {
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(time.Second))
+			conn.Close()
+		}
+
+		// Start can't launch the supervisor once stop is closed, so
+		// without one nothing else will close done.
+		if !started {
+			close(g.done)
+		}
+	})
+
+	<-g.done
+}
+
+// Done is closed once the supervisor has exited, either because Close was
+// called or because Discord rejected the session for good.
+func (g *Gateway) Done() <-chan struct{} {
+	return g.done
+}
+
+// State returns the current connection state.
+func (g *Gateway) State() GatewayState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state
+}
+
+// Latency returns the round-trip time of the most recently acknowledged
+// heartbeat, or zero if no heartbeat has been acknowledged yet.
+func (g *Gateway) Latency() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.latency
+}
+
+func (g *Gateway) setState(state GatewayState) {
+	g.mu.Lock()
+	g.state = state
+	g.mu.Unlock()
+}
+
+func (g *Gateway) stopped() bool {
+	select {
+	case <-g.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (g *Gateway) canResume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sessionID != "" && g.resumeURL != ""
+}
+
+// resetSession forgets the current session so the next connect sends a fresh
+// IDENTIFY instead of a RESUME.
+func (g *Gateway) resetSession() {
+	g.mu.Lock()
+	g.sessionID = ""
+	g.resumeURL = ""
+	g.sequence = 0
+	g.mu.Unlock()
+}
+
+func (g *Gateway) run() {
+	defer close(g.done)
+	defer g.setState(GatewayClosed)
+
+	attempt := 0
+	for {
+		ready, err := g.runSession()
+		if g.stopped() {
+			return
+		}
+
+		if code, ok := fatalCloseCode(err); ok {
+			fmt.Printf("Gateway closed with code %d, not reconnecting: %v\n", code, err)
+			return
+		}
+
+		if ready {
+			attempt = 0
+		}
+
+		delay := gatewayBackoff(attempt)
+		attempt++
+
+		fmt.Printf("Gateway disconnected: %v (reconnecting in %s)\n", err, delay.Round(time.Millisecond))
+		g.setState(GatewayConnecting)
+
+		select {
+		case <-g.stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runSession opens one websocket connection and serves it until it drops.
+// It reports whether the session reached READY or RESUMED.
+func (g *Gateway) runSession() (bool, error) {
+	resuming := g.canResume()
+
+	var gatewayURL string
+	if resuming {
+		g.setState(GatewayResuming)
+
+		g.mu.Lock()
+		gatewayURL = g.resumeURL
+		g.mu.Unlock()
+	} else {
+		var err error
+		gatewayURL, err = getGatewayURL()
+		if err != nil {
+			return false, fmt.Errorf("failed to get gateway URL: %w", err)
+		}
+	}
+
+	params := "/?v=10&encoding=json"
+
+	var inflater *zlibStream
+	if currentConfig().GatewayCompress {
+		params += "&compress=zlib-stream"
+		inflater = newZlibStream()
+		defer inflater.Close()
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(gatewayURL+params, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to gateway: %w", err)
+	}
+	defer func() {
+		// Close has nothing to send a close frame on between sessions.
+		g.mu.Lock()
+		g.conn = nil
+		g.mu.Unlock()
+
+		conn.Close()
+	}()
+
+	g.mu.Lock()
+	g.conn = conn
+	g.mu.Unlock()
+
+	if g.stopped() {
+		return false, errGatewayClosed
+	}
+
+	var payload WSPayload
+	if err := readPayload(conn, inflater, &payload); err != nil {
+		return false, fmt.Errorf("failed to read hello: %w", err)
+	}
+
+	if payload.Op != GatewayOpcodeHello {
+		return false, fmt.Errorf("expected hello op code, got %d", payload.Op)
+	}
+
+	var helloData struct {
+		HeartbeatInterval int `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(payload.D, &helloData); err != nil {
+		return false, fmt.Errorf("failed to parse hello data: %w", err)
+	}
+
+	g.mu.Lock()
+	g.heartbeatAcked = true
+	g.mu.Unlock()
+
+	heartbeatDone := make(chan struct{})
+	var heartbeatWG sync.WaitGroup
+	heartbeatWG.Add(1)
+	go func() {
+		defer heartbeatWG.Done()
+		g.heartbeat(conn, time.Duration(helloData.HeartbeatInterval)*time.Millisecond, heartbeatDone)
+	}()
+	defer func() {
+		close(heartbeatDone)
+		heartbeatWG.Wait()
+	}()
+
+	if resuming {
+		if err := g.sendResume(conn); err != nil {
+			return false, fmt.Errorf("failed to resume: %w", err)
+		}
+	} else {
+		g.setState(GatewayIdentifying)
+
+		if err := g.sendIdentify(conn); err != nil {
+			return false, fmt.Errorf("failed to identify: %w", err)
+		}
+	}
+
+	return g.readLoop(conn, inflater)
+}
+
+// readPayload reads the next gateway payload, inflating binary frames when
+// the connection uses zlib-stream transport compression.
+func readPayload(conn *websocket.Conn, inflater *zlibStream, payload *WSPayload) error {
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		if inflater != nil && messageType == websocket.BinaryMessage {
+			data, err = inflater.Feed(data)
+			if err != nil {
+				return fmt.Errorf("failed to inflate payload: %w", err)
+			}
+			if data == nil { // The message continues in the next frame.
+				continue
+			}
+		}
+
+		return json.Unmarshal(data, payload)
+	}
+}
+
+func (g *Gateway) readLoop(conn *websocket.Conn, inflater *zlibStream) (bool, error) {
+	ready := false
+
+	for {
+		var payload WSPayload
+		if err := readPayload(conn, inflater, &payload); err != nil {
+			// Invalid sequence and session timeout can't be resumed.
+			if websocket.IsCloseError(err, 4007, 4009) {
+				g.resetSession()
+			}
+			return ready, err
+		}
+
+		if payload.S != 0 {
+			g.mu.Lock()
+			g.sequence = payload.S
+			g.mu.Unlock()
+		}
+
+		switch payload.Op {
+		case GatewayOpcodeDispatch:
+			switch payload.T {
+			case "READY":
+				var readyData struct {
+					SessionID        string `json:"session_id"`
+					ResumeGatewayURL string `json:"resume_gateway_url"`
+				}
+				if err := json.Unmarshal(payload.D, &readyData); err != nil {
+					return ready, fmt.Errorf("failed to parse READY data: %w", err)
+				}
+
+				g.mu.Lock()
+				g.sessionID = readyData.SessionID
+				g.resumeURL = readyData.ResumeGatewayURL
+				g.mu.Unlock()
+
+				g.setState(GatewayReady)
+				ready = true
+			case "RESUMED":
+				g.setState(GatewayReady)
+				ready = true
+			}
+
+			g.dispatch(payload)
+
+		case GatewayOpcodeHeartbeat:
+			if err := g.sendHeartbeat(conn); err != nil {
+				return ready, err
+			}
+
+		case GatewayOpcodeHeartbeatACK:
+			g.mu.Lock()
+			g.heartbeatAcked = true
+			g.latency = time.Since(g.heartbeatSent)
+			g.mu.Unlock()
+
+		case GatewayOpcodeReconnect:
+			return ready, errReconnectRequest
+
+		case GatewayOpcodeInvalidSession:
+			var resumable bool
+			json.Unmarshal(payload.D, &resumable)
+
+			if !resumable {
+				g.resetSession()
+			}
+
+			// Discord asks for a random 1-5 second wait before reconnecting.
+			select {
+			case <-g.stop:
+			case <-time.After(time.Duration(1000+rand.Intn(4000)) * time.Millisecond):
+			}
+
+			return ready, errInvalidSession
+		}
+	}
+}
+
+func (g *Gateway) heartbeat(conn *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	// The first heartbeat is jittered so that clients reconnecting at the
+	// same time don't heartbeat in lockstep.
+	timer := time.NewTimer(time.Duration(rand.Float64() * float64(interval)))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+			g.mu.Lock()
+			acked := g.heartbeatAcked
+			g.mu.Unlock()
+
+			if !acked {
+				fmt.Println("Heartbeat was not acknowledged, treating connection as dead")
+				// Any close code other than 1000/1001 keeps the session
+				// resumable.
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(4000, "heartbeat not acknowledged"),
+					time.Now().Add(time.Second))
+				conn.Close()
+				return
+			}
+
+			if err := g.sendHeartbeat(conn); err != nil {
+				fmt.Printf("Error sending heartbeat: %v\n", err)
+				// Closing the connection unblocks the read loop, which
+				// hands control back to the supervisor.
+				conn.Close()
+				return
+			}
+
+			timer.Reset(interval)
+		}
+	}
+}
+
+func (g *Gateway) write(conn *websocket.Conn, payload interface{}) error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	return conn.WriteJSON(payload)
+}
+
+func (g *Gateway) sendHeartbeat(conn *websocket.Conn) error {
+	g.mu.Lock()
+	var seq interface{}
+	if g.sequence != 0 {
+		seq = g.sequence
+	}
+	g.mu.Unlock()
+
+	if err := g.write(conn, map[string]interface{}{
+		"op": GatewayOpcodeHeartbeat,
+		"d":  seq,
+	}); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.heartbeatSent = time.Now()
+	g.heartbeatAcked = false
+	g.mu.Unlock()
+
+	return nil
+}
+
+func (g *Gateway) sendResume(conn *websocket.Conn) error {
+	g.mu.Lock()
+	sessionID, seq := g.sessionID, g.sequence
+	g.mu.Unlock()
+
+	fmt.Printf("Resuming session %s at sequence %d\n", sessionID, seq)
+
+	return g.write(conn, map[string]interface{}{
+		"op": GatewayOpcodeResume,
+		"d": map[string]interface{}{
+			"token":      currentConfig().Token,
+			"session_id": sessionID,
+			"seq":        seq,
+		},
+	})
+}
+
+func (g *Gateway) sendIdentify(conn *websocket.Conn) error {
+	return g.write(conn, map[string]interface{}{
+		"op": GatewayOpcodeIdentify,
+		"d": map[string]interface{}{
+			"token": currentConfig().Token,
+			"properties": map[string]string{
+				"os":      runtime.GOOS,
+				"browser": "Chrome",
+				"device":  "selfbot",
+			},
+			"compress": false,
+			"presence": map[string]interface{}{
+				"since":      nil,
+				"activities": []interface{}{},
+				"status":     "online",
+				"afk":        false,
+			},
+		},
+	})
+}
+
+// fatalCloseCode reports whether err is a close frame Discord documents as
+// not worth reconnecting after, such as a rejected token.
+func fatalCloseCode(err error) (int, bool) {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return 0, false
+	}
+
+	switch closeErr.Code {
+	case 4004, 4010, 4011, 4012, 4013, 4014:
+		return closeErr.Code, true
+	}
+
+	return 0, false
+}
+
+// gatewayBackoff returns the delay before reconnect attempt n, doubling from
+// gatewayBackoffBase up to gatewayBackoffMax with half of it randomized.
+func gatewayBackoff(attempt int) time.Duration {
+	delay := gatewayBackoffMax
+	if attempt < 8 {
+		delay = gatewayBackoffBase << attempt
+		if delay > gatewayBackoffMax {
+			delay = gatewayBackoffMax
+		}
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func getGatewayURL() (string, error) {
+	var data struct {
+		URL string `json:"url"`
+	}
+
+	if err := rest.Do("GET", "/gateway", nil, &data); err != nil {
+		return "", err
+	}
+
+	return data.URL, nil
+}
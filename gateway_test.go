@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// gatewayPeer is the server's end of a connection the gateway under test
+// opened.
+type gatewayPeer struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// startFakeGateway starts a gateway against a stand-in for Discord's, and
+// returns it and the server's end of every connection it opens, in order.
+func startFakeGateway(t *testing.T) (*Gateway, <-chan *gatewayPeer, string) {
+	t.Helper()
+
+	saved := configStore
+	configStore = NewConfigStore("")
+	configStore.Replace(Config{Token: "token"})
+	t.Cleanup(func() { configStore = saved })
+
+	peers := make(chan *gatewayPeer, 4)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+		peers <- &gatewayPeer{t, conn}
+	}))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	defaultREST := rest
+	rest = newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"url": url})
+	})
+	t.Cleanup(func() { rest = defaultREST })
+
+	g := NewGateway(func(WSPayload) {})
+	g.Start()
+	t.Cleanup(g.Close)
+
+	return g, peers, url
+}
+
+func nextPeer(t *testing.T, peers <-chan *gatewayPeer, within time.Duration) *gatewayPeer {
+	t.Helper()
+
+	select {
+	case peer := <-peers:
+		return peer
+	case <-time.After(within):
+		t.Fatal("the gateway didn't connect")
+		return nil
+	}
+}
+
+func (peer *gatewayPeer) send(payload map[string]any) {
+	peer.t.Helper()
+
+	if err := peer.conn.WriteJSON(payload); err != nil {
+		peer.t.Fatal(err)
+	}
+}
+
+func (peer *gatewayPeer) hello(interval time.Duration) {
+	peer.send(map[string]any{"op": GatewayOpcodeHello, "d": map[string]any{"heartbeat_interval": interval.Milliseconds()}})
+}
+
+// expect reads the next payload that isn't a heartbeat, and fails unless
+// it's op.
+func (peer *gatewayPeer) expect(op int) WSPayload {
+	peer.t.Helper()
+
+	peer.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var payload WSPayload
+		if err := peer.conn.ReadJSON(&payload); err != nil {
+			peer.t.Fatalf("waiting for op %d: %v", op, err)
+		}
+		if payload.Op == GatewayOpcodeHeartbeat {
+			continue
+		}
+		if payload.Op != op {
+			peer.t.Fatalf("got op %d, want %d", payload.Op, op)
+		}
+		return payload
+	}
+}
+
+// identify takes the gateway through IDENTIFY and READY for session, and
+// leaves it at sequence seq.
+func (peer *gatewayPeer) identify(interval time.Duration, session, resumeURL string, seq int) {
+	peer.t.Helper()
+
+	peer.hello(interval)
+	peer.expect(GatewayOpcodeIdentify)
+	peer.send(map[string]any{"op": GatewayOpcodeDispatch, "t": "READY", "s": 1, "d": map[string]any{"session_id": session, "resume_gateway_url": resumeURL}})
+	peer.send(map[string]any{"op": GatewayOpcodeDispatch, "t": "TYPING_START", "s": seq, "d": map[string]any{}})
+}
+
+func TestGatewayCloseWithoutStart(t *testing.T) {
+	g := NewGateway(func(WSPayload) {})
+
+	closed := make(chan struct{})
+	go func() {
+		g.Close()
+		close(closed)
+	}()
+	waitFor(t, closed, "Close on a gateway that was never started")
+
+	// A closed gateway can't be started again.
+	g.Start()
+	if state := g.State(); state != GatewayClosed {
+		t.Errorf("got state %s after starting a closed gateway", state)
+	}
+	waitFor(t, g.Done(), "Done")
+}
+
+func TestFatalCloseCode(t *testing.T) {
+	tests := []struct {
+		err   error
+		code  int
+		fatal bool
+	}{
+		{&websocket.CloseError{Code: 4004}, 4004, true},
+		{&websocket.CloseError{Code: 4014}, 4014, true},
+		{fmt.Errorf("session: %w", &websocket.CloseError{Code: 4010}), 4010, true},
+		{&websocket.CloseError{Code: 4000}, 0, false},
+		{&websocket.CloseError{Code: 4009}, 0, false},
+		{&websocket.CloseError{Code: websocket.CloseNormalClosure}, 0, false},
+		{errors.New("connection reset"), 0, false},
+		{nil, 0, false},
+	}
+
+	for _, test := range tests {
+		if code, fatal := fatalCloseCode(test.err); code != test.code || fatal != test.fatal {
+			t.Errorf("fatalCloseCode(%v) = %d, %v, want %d, %v", test.err, code, fatal, test.code, test.fatal)
+		}
+	}
+}
+
+func TestGatewayBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{3, 8 * time.Second},
+		{6, 64 * time.Second},
+		{7, gatewayBackoffMax},
+		{8, gatewayBackoffMax},
+		{100, gatewayBackoffMax},
+	}
+
+	for _, test := range tests {
+		for range 100 {
+			if delay := gatewayBackoff(test.attempt); delay < test.max/2 || delay > test.max {
+				t.Errorf("attempt %d waited %v, want %v to %v", test.attempt, delay, test.max/2, test.max)
+				break
+			}
+		}
+	}
+}
+
+func TestGatewayResumesAfterADrop(t *testing.T) {
+	_, peers, url := startFakeGateway(t)
+
+	first := nextPeer(t, peers, 5*time.Second)
+	first.identify(time.Minute, "session-1", url, 5)
+
+	// Dropping the connection without a close frame keeps the session.
+	first.conn.Close()
+
+	second := nextPeer(t, peers, 5*time.Second)
+	second.hello(time.Minute)
+	payload := second.expect(GatewayOpcodeResume)
+
+	var resume struct {
+		Token     string `json:"token"`
+		SessionID string `json:"session_id"`
+		Seq       int    `json:"seq"`
+	}
+	json.Unmarshal(payload.D, &resume)
+	if resume.Token != "token" || resume.SessionID != "session-1" || resume.Seq != 5 {
+		t.Errorf("resumed with %+v", resume)
+	}
+}
+
+func TestGatewayIdentifiesAfterAnUnresumableInvalidSession(t *testing.T) {
+	_, peers, url := startFakeGateway(t)
+
+	first := nextPeer(t, peers, 5*time.Second)
+	first.identify(time.Minute, "session-1", url, 5)
+	first.send(map[string]any{"op": GatewayOpcodeInvalidSession, "d": false})
+
+	// The gateway waits up to 5 seconds before reconnecting, and then backs
+	// off.
+	second := nextPeer(t, peers, 10*time.Second)
+	second.hello(time.Minute)
+	second.expect(GatewayOpcodeIdentify)
+}
+
+func TestGatewayStopsOnAFatalCloseCode(t *testing.T) {
+	g, peers, _ := startFakeGateway(t)
+
+	peer := nextPeer(t, peers, 5*time.Second)
+	peer.hello(time.Minute)
+	peer.expect(GatewayOpcodeIdentify)
+
+	// A rejected token isn't worth reconnecting after.
+	peer.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(4004, "Authentication failed."),
+		time.Now().Add(time.Second))
+
+	waitFor(t, g.Done(), "the supervisor to stop")
+	if state := g.State(); state != GatewayClosed {
+		t.Errorf("got state %s", state)
+	}
+	select {
+	case <-peers:
+		t.Error("the gateway reconnected")
+	default:
+	}
+}
+
+func TestGatewayReconnectsWhenHeartbeatsAreNotAcknowledged(t *testing.T) {
+	_, peers, url := startFakeGateway(t)
+
+	first := nextPeer(t, peers, 5*time.Second)
+	first.identify(100*time.Millisecond, "session-1", url, 5)
+
+	// Heartbeats go unanswered, so the gateway gives up on the connection
+	// with a close code that keeps the session.
+	first.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	heartbeats := 0
+	for {
+		var payload WSPayload
+		err := first.conn.ReadJSON(&payload)
+		if websocket.IsCloseError(err, 4000) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("got %v, want close code 4000", err)
+		}
+		if payload.Op == GatewayOpcodeHeartbeat {
+			heartbeats++
+		}
+	}
+	if heartbeats != 1 {
+		t.Errorf("sent %d heartbeats before giving up, want 1", heartbeats)
+	}
+
+	second := nextPeer(t, peers, 5*time.Second)
+	second.hello(time.Minute)
+	second.expect(GatewayOpcodeResume)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rerunWindow is how long after running a command it's re-run when its
+// message is edited.
+const rerunWindow = 5 * time.Minute
+
+// invocation is a command message and the messages sent in response to it,
+// kept so that editing the command can re-run it and edit those responses
+// in place.
+type invocation struct {
+	channelID string
+	content   string // What the command message said when it last ran.
+	at        time.Time
+	running   bool
+	responses []string
+	previous  []string // Responses of the last run, reused in order by this one.
+}
+
+var (
+	invocationMutex sync.Mutex
+	invocations     = make(map[string]*invocation) // By command message ID.
+)
+
+// startInvocation starts tracking a run of the command in message, unless
+// it's already running because it's being re-run.
+func startInvocation(message Message) {
+	invocationMutex.Lock()
+	defer invocationMutex.Unlock()
+
+	now := time.Now()
+	for id, inv := range invocations {
+		if !inv.running && now.Sub(inv.at) > rerunWindow {
+			delete(invocations, id)
+		}
+	}
+
+	if inv, ok := invocations[message.ID]; ok && inv.running {
+		return
+	}
+
+	invocations[message.ID] = &invocation{
+		channelID: message.ChannelID,
+		content:   message.Content,
+		at:        now,
+		running:   true,
+	}
+}
+
+// claimRerun prepares the edited message's command to be re-run. It reports
+// false if the message isn't a command that ran in the last rerunWindow, is
+// still running, or hasn't changed since it ran.
+func claimRerun(message Message) bool {
+	invocationMutex.Lock()
+	defer invocationMutex.Unlock()
+
+	inv, ok := invocations[message.ID]
+	if !ok || inv.running || inv.content == message.Content || time.Since(inv.at) > rerunWindow {
+		return false
+	}
+
+	inv.content = message.Content
+	inv.running = true
+	inv.previous = inv.responses
+	inv.responses = nil
+	return true
+}
+
+// finishInvocation marks a run of the command in message as done and deletes
+// the responses of its last run that this one didn't reuse. If forget is set
+// the command message is gone, and so is its invocation.
+func finishInvocation(message Message, forget bool) {
+	invocationMutex.Lock()
+	inv, ok := invocations[message.ID]
+	if !ok {
+		invocationMutex.Unlock()
+		return
+	}
+
+	stale := inv.previous
+	inv.previous = nil
+	inv.running = false
+	if forget {
+		delete(invocations, message.ID)
+	}
+	invocationMutex.Unlock()
+
+	for _, id := range stale {
+		if err := deleteMessage(inv.channelID, id); err != nil && !errors.Is(err, ErrUnknownMessage) {
+			fmt.Printf("Failed to delete old response %s: %v\n", id, err)
+		}
+	}
+}
+
+// nextResponse returns the running invocation of message and, if reuse is
+// set, the oldest response of its last run that hasn't been reused yet.
+func nextResponse(message Message, reuse bool) (*invocation, string) {
+	invocationMutex.Lock()
+	defer invocationMutex.Unlock()
+
+	inv, ok := invocations[message.ID]
+	if !ok || !inv.running {
+		return nil, ""
+	}
+
+	if !reuse || len(inv.previous) == 0 {
+		return inv, ""
+	}
+
+	id := inv.previous[0]
+	inv.previous = inv.previous[1:]
+	return inv, id
+}
+
+func (inv *invocation) record(messageID string) {
+	invocationMutex.Lock()
+	inv.responses = append(inv.responses, messageID)
+	invocationMutex.Unlock()
+}
+
+// reply sends content in response to the command in message, splitting it
+// like sendMessage, and returns the first message. When the command is being
+// re-run after an edit, the last run's responses are edited in place instead.
+func reply(message Message, content string) (Message, error) {
+	content, followUps := splitContent(content)
+
+	first, err := respond(message, NewMessage(content))
+	if err != nil {
+		return Message{}, err
+	}
+
+	for _, msg := range followUps {
+		if _, err := respond(message, msg); err != nil {
+			return first, err
+		}
+	}
+
+	return first, nil
+}
+
+func respond(message Message, msg *MessageSend) (Message, error) {
+	// An edit can't swap attachments, so a reply with files is always sent as
+	// a new message, and the response it replaces is deleted when the run
+	// finishes.
+	inv, previous := nextResponse(message, len(msg.files) == 0)
+
+	if previous != "" {
+		edited, err := patchMessage(message.ChannelID, previous, msg)
+		if err == nil {
+			inv.record(edited.ID)
+			return edited, nil
+		}
+		if !errors.Is(err, ErrUnknownMessage) {
+			return Message{}, err
+		}
+	}
+
+	sent, err := postMessage(message.ChannelID, msg)
+	if err == nil && inv != nil {
+		inv.record(sent.ID)
+	}
+	return sent, err
+}
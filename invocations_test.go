@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRerunEditsResponsesInPlace(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		next     = 200000000000000000
+	)
+
+	defaultREST := rest
+	rest = newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/channels/1/messages"))
+
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if r.Method == http.MethodPost {
+			next++
+			id = fmt.Sprint(next)
+		}
+		json.NewEncoder(w).Encode(Message{ID: id, ChannelID: "1"})
+	})
+	t.Cleanup(func() { rest = defaultREST })
+
+	command := Message{ID: "100000000000000001", ChannelID: "1", Content: "&wether"}
+
+	startInvocation(command)
+	reply(command, "first")
+	reply(command, "second")
+	finishInvocation(command, false)
+
+	if claimRerun(command) {
+		t.Error("an unchanged command was re-run")
+	}
+
+	command.Content = "&weather"
+	if !claimRerun(command) {
+		t.Fatal("the edited command wasn't re-run")
+	}
+	startInvocation(command)
+	reply(command, "edited")
+	finishInvocation(command, true)
+
+	want := []string{
+		"POST ",
+		"POST ",
+		"PATCH /200000000000000001",
+		"DELETE /200000000000000002",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+
+	command.Content = "&ping"
+	if claimRerun(command) {
+		t.Error("a forgotten command was re-run")
+	}
+}
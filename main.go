@@ -1,9 +1,10 @@
 package main
 
 import (
-	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"math/rand"
@@ -11,44 +12,46 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
-	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
-
-	"github.com/gorilla/websocket"
+	"unicode"
 )
 
 type Config struct {
-	Token               string `json:"token"`
-	OwnerID             string `json:"OwnerID"`
+	Version             int    `json:"version"`
+	Token               string `json:"token" secret:"true"`
+	OwnerID             string `json:"owner_id"`
 	Prefix              string `json:"prefix"`
-	GeminiAPIKey        string `json:"gemini_api_key"`
+	GeminiAPIKey        string `json:"gemini_api_key" secret:"true"`
 	AutoResponseEnabled bool   `json:"auto_response_enabled"`
 	AutoResponsePhrase  string `json:"auto_response_phrase"`
 	AutoReactEmojiEnabled bool `json:"auto_emoji_enabled"`
 	AutoReactEmoji string `json:"auto_emoji"`
+	GatewayCompress bool `json:"gateway_compress"`
+	LongMessagesAsFile bool `json:"long_messages_as_file"`
+	SendsPerMinute int `json:"sends_per_minute"`
+	SendBurst int `json:"send_burst"`
+	Aliases map[string]string `json:"aliases,omitempty"`
+	Features map[string]bool `json:"features,omitempty"`
+	UIPort int `json:"ui_port"`
+	RPC *RPCConfig `json:"rpc,omitempty"`
 }
 
 type Message struct {
-	ID        string `json:"id"`
-	ChannelID string `json:"channel_id"`
-	Content   string `json:"content"`
-	Timestamp string `json:"timestamp"`
-	Author    struct {
-		ID       string `json:"id"`
-		Username string `json:"username"`
-		Bot      bool   `json:"bot"`
-		Avatar   string `json:"avatar"`
-	} `json:"author"`
-	Mentions []struct {
-		ID       string `json:"id"`
-		Username string `json:"username"`
-		Avatar   string `json:"avatar"`
-	} `json:"mentions"`
+	ID              string       `json:"id"`
+	ChannelID       string       `json:"channel_id"`
+	GuildID         string       `json:"guild_id"`
+	Content         string       `json:"content"`
+	Timestamp       string       `json:"timestamp"`
+	EditedTimestamp string       `json:"edited_timestamp"`
+	Author          User         `json:"author"`
+	Mentions        []User       `json:"mentions"`
+	Attachments     []Attachment `json:"attachments"`
 }
 
 type WSPayload struct {
@@ -81,12 +84,8 @@ const (
 )
 
 var (
-	config          Config
-	wsConn          *websocket.Conn
-	heartbeatTicker *time.Ticker
-	sequence        int
-	sessionID       string
-	lastHeartbeat   time.Time
+	gateway         *Gateway
+	bus             = NewEventBus(256)
 	lastMessageID   string
 	startTime       = time.Now()
 
@@ -109,44 +108,6 @@ var (
 	urbanCache = make(map[string][]UrbanDefinition)
 )
 
-func init() {
-	configFile, err := os.ReadFile("config.json")
-	if err != nil {
-		fmt.Println("Error reading config file:", err)
-		if os.IsNotExist(err) {
-			config = Config{
-				Token:        "YOUR_TOKEN_HERE",
-				OwnerID:      "",
-				Prefix:       "&",
-				GeminiAPIKey: "YOUR_GEMINI_API_KEY",
-			}
-			saveConfig()
-			fmt.Println("Created default config file. Please edit config.json with your token and restart.")
-			os.Exit(0)
-		}
-		os.Exit(1)
-	}
-
-	err = json.Unmarshal(configFile, &config)
-	if err != nil {
-		fmt.Println("Error parsing config:", err)
-		os.Exit(1)
-	}
-
-	if config.Token == "" || config.Token == "YOUR_TOKEN_HERE" {
-		fmt.Println("Please set your Discord token in config.json")
-		os.Exit(1)
-	}
-
-	if config.GeminiAPIKey == "" || config.GeminiAPIKey == "YOUR_GEMINI_API_KEY" {
-		fmt.Println("Please set your Gemini API key in config.json for the &ai command (this message is shown even if api key is active, gemini became retarted)")
-	}
-
-	autoResponderEnabled = config.AutoResponseEnabled
-
-	rand.Seed(time.Now().UnixNano())
-}
-
 type IPGeolocation struct {
 	IP        string  `json:"ip"`
 	City      string  `json:"city"`
@@ -176,29 +137,8 @@ type WeatherData struct {
 }
 
 func triggerTyping(channelID string) {
-	req, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("https://discord.com/api/v10/channels/%s/typing", channelID),
-		nil,
-	)
-	if err != nil {
-		return
-	}
-
-	req.Header.Set("Authorization", config.Token)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/143.0.0.0 Safari/537.36")
-	req.Header.Set("Content-Type", "application/json")
-
-
-	//time.Sleep(1 * time.Second) (make faster )
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		fmt.Printf("Typing failed, status: %d\n", resp.StatusCode)
+	if err := rest.Do("POST", fmt.Sprintf("/channels/%s/typing", channelID), nil, nil); err != nil {
+		fmt.Println("Typing failed:", err)
 		return
 	}
 
@@ -207,26 +147,8 @@ func triggerTyping(channelID string) {
 }
 
 func triggerTypingAP(channelID string) {
-	req, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("https://discord.com/api/v10/channels/%s/typing", channelID),
-		nil,
-	)
-	if err != nil {
-		return
-	}
-
-	req.Header.Set("Authorization", config.Token)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/143.0.0.0 Safari/537.36")
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		fmt.Printf("Typing failed, status: %d\n", resp.StatusCode)
+	if err := rest.Do("POST", fmt.Sprintf("/channels/%s/typing", channelID), nil, nil); err != nil {
+		fmt.Println("Typing failed:", err)
 		return
 	}
 
@@ -234,684 +156,268 @@ func triggerTypingAP(channelID string) {
 	time.Sleep(time.Duration(delay) * time.Millisecond)
 }
 
-func connectWebsocket() error {
-	gatewayURL, err := getGatewayURL()
-	if err != nil {
-		return fmt.Errorf("failed to get gateway URL: %w", err)
-	}
+// handleDispatch decodes a dispatch (op 0) payload forwarded by the gateway
+// and publishes it on the event bus.
+func handleDispatch(payload WSPayload) {
+	fmt.Printf("Received message: op=%d, t=%s\n", payload.Op, payload.T)
 
-	conn, _, err := websocket.DefaultDialer.Dial(gatewayURL+"/?v=10&encoding=json", nil)
+	event, err := decodeEvent(payload.T, payload.D)
 	if err != nil {
-		return fmt.Errorf("failed to connect to gateway: %w", err)
-	}
-
-	wsConn = conn
-
-	var payload WSPayload
-	if err := wsConn.ReadJSON(&payload); err != nil {
-		return fmt.Errorf("failed to read hello: %w", err)
-	}
-
-	if payload.Op != GatewayOpcodeHello {
-		return fmt.Errorf("expected hello op code, got %d", payload.Op)
-	}
-
-	var helloData struct {
-		HeartbeatInterval int `json:"heartbeat_interval"`
-	}
-	if err := json.Unmarshal(payload.D, &helloData); err != nil {
-		return fmt.Errorf("failed to parse hello data: %w", err)
-	}
-
-	go startHeartbeat(helloData.HeartbeatInterval)
-
-	identify := map[string]interface{}{
-		"op": GatewayOpcodeIdentify,
-		"d": map[string]interface{}{
-			"token": config.Token,
-			"properties": map[string]string{
-				"os":      runtime.GOOS,
-				"browser": "Chrome",
-				"device":  "selfbot",
-			},
-			"compress": false,
-			"presence": map[string]interface{}{
-				"since":      nil,
-				"activities": []interface{}{},
-				"status":     "online",
-				"afk":        false,
-			},
-		},
+		fmt.Printf("Error decoding event: %v\n", err)
+		return
 	}
 
-	if err := wsConn.WriteJSON(identify); err != nil {
-		return fmt.Errorf("failed to identify: %w", err)
+	if event != nil && !bus.Publish(event) {
+		fmt.Printf("Event bus full, dropped %s\n", payload.T)
 	}
-
-	return nil
 }
 
-func getGatewayURL() (string, error) {
-	req, err := http.NewRequest("GET", "https://discord.com/api/v10/gateway", nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", config.Token)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+func registerEventHandlers() {
+	Subscribe(bus, DeliverOrdered, onReady)
+	Subscribe(bus, DeliverOrdered, onResumed)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
+	cfg := currentConfig()
+	for _, f := range features {
+		f.setEnabled(bus, featureEnabled(cfg, f.name))
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("bad status code: %d", resp.StatusCode)
-	}
-
-	var data struct {
-		URL string `json:"url"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", err
-	}
-
-	return data.URL, nil
 }
 
-func startHeartbeat(interval int) {
-	heartbeatTicker = time.NewTicker(time.Duration(interval) * time.Millisecond)
-	defer heartbeatTicker.Stop()
-
-	for range heartbeatTicker.C {
-		heartbeat := map[string]interface{}{
-			"op": GatewayOpcodeHeartbeat,
-			"d":  sequence,
-		}
-
-		if err := wsConn.WriteJSON(heartbeat); err != nil {
-			fmt.Printf("Error sending heartbeat: %v\n", err)
-			if err := connectWebsocket(); err != nil {
-				fmt.Printf("Failed to reconnect: %v\n", err)
-			}
-			return
-		}
-	}
+func onReady(ready *Ready) {
+	setSelfUser(ready.User)
+	fmt.Printf("Connected as %s\n", ready.User.Username)
 }
 
-func listenForMessages() {
-	for {
-		var payload WSPayload
-		if err := wsConn.ReadJSON(&payload); err != nil {
-			fmt.Printf("Error reading from websocket: %v\n", err)
-
-			if err := connectWebsocket(); err != nil {
-				fmt.Printf("Failed to reconnect: %v\n", err)
-				time.Sleep(5 * time.Second)
-			}
-			continue
-		}
-
-		if payload.S != 0 {
-			sequence = payload.S
-		}
-
-		switch payload.Op {
-		case GatewayOpcodeDispatch:
-			fmt.Printf("Received message: op=%d, t=%s\n", payload.Op, payload.T)
-
-			switch payload.T {
-			case "READY":
-				var readyData struct {
-					SessionID string `json:"session_id"`
-					User      struct {
-						ID       string `json:"id"`
-						Username string `json:"username"`
-					} `json:"user"`
-				}
-
-				if err := json.Unmarshal(payload.D, &readyData); err != nil {
-					fmt.Printf("Error parsing READY data: %v\n", err)
-					continue
-				}
-
-				sessionID = readyData.SessionID
-				fmt.Printf("Connected as %s\n", readyData.User.Username)
-
-			case "MESSAGE_CREATE":
-				var message Message
-				if err := json.Unmarshal(payload.D, &message); err != nil {
-					fmt.Printf("Error parsing MESSAGE_CREATE data: %v\n", err)
-					continue
-				}
-
-				messageTime, err := time.Parse(time.RFC3339, message.Timestamp)
-				if err != nil || !messageTime.After(startTime) {
-					continue
-				}
-
-				if !message.Author.Bot {
-					fmt.Printf("Message from %s: %s\n", message.Author.Username, message.Content)
-
-					statsMutex.Lock()
-					messagesLogged++
-					statsMutex.Unlock()
-
-					ownerIDStr := config.OwnerID
-		if message.Author.ID == ownerIDStr && config.AutoReactEmojiEnabled && config.AutoReactEmoji != "" {
-			fmt.Printf("DEBUG: Auto-reacting with %s\n", config.AutoReactEmoji)
-			go sendReaction(message.ChannelID, message.ID, config.AutoReactEmoji)
-		}
-					if message.Author.ID != ownerIDStr && autoResponderEnabled {
-						selfMentioned := false
-						for _, mention := range message.Mentions {
-							if mention.ID == ownerIDStr {
-								selfMentioned = true
-								break
-							}
-						}
-
-						if !selfMentioned && strings.Contains(message.Content, "<@"+ownerIDStr+">") {
-							selfMentioned = true
-						}
-
-						if selfMentioned {
-							fmt.Printf("Autoresponder triggered by %s\n", message.Author.Username)
-							response := config.AutoResponsePhrase
-							if response == "" {
-								response = "I'm currently unavailable. Please try again later."
-							}
-							response = strings.ReplaceAll(response, "<user>", message.Author.Username)
-							autoResponse := fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s```", response)
-							sendMessage(message.ChannelID, autoResponse)
-						}
-					}
-				}
-
-				ownerIDStr := config.OwnerID
-				fmt.Printf("Message author ID: %s, Owner ID: %s\n", message.Author.ID, ownerIDStr)
-
-				if message.Author.ID == ownerIDStr || message.Author.Username == "ndq2" {
-					fmt.Printf("Owner command detected: %s\n", message.Content)
-					if strings.HasPrefix(message.Content, config.Prefix) {
-    statsMutex.Lock()
-    commandsHandled++
-    statsMutex.Unlock()
-
-    // Trigger typing indicator and simulate huma shi
-    go func(channelID string) {
-       triggerTyping(channelID)
-
-        // Random delay between 2.0 and 2.5 seconds (removed to make faster)
-        //delay := 2000 + rand.Intn(501) // 2000-2500 ms
-        //time.Sleep(time.Duration(delay) * time.Millisecond)
-
-        // Now process the command after "typing"
-        handleMessage(message)
-    }(message.ChannelID)
-
-    // Do not call handleMessage directly — it's now async after typing
-    continue // Skip further processing for this message
+func onResumed(*Resumed) {
+	fmt.Println("Resumed gateway session")
 }
-				} else {
-					/* fmt.Printf("Message not from owner! Author: %s (ID: %s), Owner: %s\n",
-						message.Author.Username, message.Author.ID, ownerIDStr) */
-				}
-			}
 
-		case GatewayOpcodeHeartbeatACK:
-		case GatewayOpcodeReconnect:
-			fmt.Println("Server requested reconnect")
-			if err := connectWebsocket(); err != nil {
-				fmt.Printf("Failed to reconnect: %v\n", err)
-			}
+// sendMessage sends content to channelID, splitting it over several messages
+// if it's too long for one, and returns the first.
+func sendMessage(channelID, content string) (Message, error) {
+	content, followUps := splitContent(content)
 
-		case GatewayOpcodeInvalidSession:
-			fmt.Println("Invalid session, reconnecting...")
-			time.Sleep(5 * time.Second)
-			if err := connectWebsocket(); err != nil {
-				fmt.Printf("Failed to reconnect: %v\n", err)
-			}
-		}
-	}
-}
-
-func sendMessage(channelID, content string) string {
-	fmt.Printf("Attempting to send message to channel %s: %s\n", channelID, content)
-
-	url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages", channelID)
-	fmt.Printf("POST URL: %s\n", url)
-
-	reqBody := map[string]string{
-		"content": content,
-	}
-	jsonBody, err := json.Marshal(reqBody)
+	sent, err := postMessage(channelID, NewMessage(content))
 	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return ""
+		return Message{}, err
 	}
 
-	fmt.Printf("Request body: %s\n", string(jsonBody))
+	return sent, sendFollowUps(channelID, followUps)
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return ""
+// updateStatus edits a command's status message to content, and reports in
+// the channel if it can't.
+func updateStatus(channelID string, status Message, content string) {
+	if _, err := editMessage(channelID, status.ID, content); err != nil {
+		reportError(channelID, "update the response", err)
 	}
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", config.Token)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		fmt.Println("Error sending message:", err)
-		return ""
+// updateStatusMessage is updateStatus for a status that's more than text.
+func updateStatusMessage(channelID string, status Message, msg *MessageSend) {
+	if status.ID == "" {
+		reportError(channelID, "update the response", errNoMessageID)
+		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("Error sending message: %s (status code: %d)\nResponse: %s\n",
-			resp.Status, resp.StatusCode, string(body))
-		return ""
-	} else {
-		fmt.Printf("Message sent successfully to channel %s\n", channelID)
 
-		var msgResponse struct {
-			ID string `json:"id"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&msgResponse); err != nil {
-			fmt.Println("Error parsing message response:", err)
-			return ""
-		}
-		return msgResponse.ID
+	if _, err := patchMessage(channelID, status.ID, msg); err != nil {
+		reportError(channelID, "update the response", err)
 	}
 }
 
-func editMessage(channelID, messageID, newContent string) bool {
+func editMessage(channelID, messageID, newContent string) (Message, error) {
 	if messageID == "" {
-		fmt.Println("Cannot edit message: messageID is empty")
-		return false
+		return Message{}, errNoMessageID
 	}
 
 	fmt.Printf("Attempting to edit message %s in channel %s\n", messageID, channelID)
 
-	url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages/%s", channelID, messageID)
+	newContent, followUps := splitContent(newContent)
 
 	reqBody := map[string]string{
 		"content": newContent,
 	}
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		fmt.Println("Error marshaling JSON for edit:", err)
-		return false
-	}
 
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		fmt.Println("Error creating edit request:", err)
-		return false
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", config.Token)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-	resp, err := httpClient.Do(req)
-	if err != nil {
+	var edited Message
+	if err := rest.Do("PATCH", fmt.Sprintf("/channels/%s/messages/%s", channelID, messageID), reqBody, &edited); err != nil {
 		fmt.Println("Error editing message:", err)
-		return false
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("Error editing message: %s (status code: %d)\nResponse: %s\n",
-			resp.Status, resp.StatusCode, string(body))
-		return false
+		return Message{}, err
 	}
 
 	fmt.Printf("Message %s edited successfully\n", messageID)
-	return true
+	return edited, sendFollowUps(channelID, followUps)
 }
 
-func deleteMessage(channelID, messageID string) bool {
-	url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages/%s", channelID, messageID)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		fmt.Println("Error creating delete request:", err)
-		return false
-	}
-
-	req.Header.Set("Authorization", config.Token)
-
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		fmt.Println("Error deleting message:", err)
-		return false
+func deleteMessage(channelID, messageID string) error {
+	if messageID == "" {
+		return errNoMessageID
 	}
-	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusNoContent
+	return rest.Do("DELETE", fmt.Sprintf("/channels/%s/messages/%s", channelID, messageID), nil, nil)
 }
 
-func deleteMessages(channelID string, count int) int {
-	url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages?limit=%d", channelID, count)
+// errNoMessageID is returned when a message is edited or deleted without an
+// ID, usually because sending it failed.
+var errNoMessageID = errors.New("no message ID")
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Println("Error creating request for message history:", err)
-		return 0
-	}
-
-	req.Header.Set("Authorization", config.Token)
-
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		fmt.Println("Error getting message history:", err)
-		return 0
-	}
+// describeError turns an error from the REST helpers into a short
+// explanation for chat.
+func describeError(err error) string {
+	var apiErr *APIError
 
-	var messages []struct {
-		ID string `json:"id"`
+	switch {
+	case errors.Is(err, ErrMissingPermissions):
+		return "Missing permissions"
+	case errors.Is(err, ErrMissingAccess):
+		return "Missing access to that channel"
+	case errors.Is(err, ErrUnknownChannel):
+		return "Unknown channel"
+	case errors.Is(err, ErrUnknownMessage):
+		return "Unknown message"
+	case errors.Is(err, ErrRateLimited):
+		return "Rate limited by Discord, try again in a moment"
+	case errors.As(err, &apiErr) && apiErr.Message != "":
+		return fmt.Sprintf("Discord error %d: %s", apiErr.Code, apiErr.Message)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
-		fmt.Println("Error parsing message history:", err)
-		resp.Body.Close()
-		return 0
-	}
-	resp.Body.Close()
+	return err.Error()
+}
 
-	deletedCount := 0
-	for _, msg := range messages {
-		if deleteMessage(channelID, msg.ID) {
-			deletedCount++
+// reportError logs a failed action and tells the user about it in
+// channelID. If the report can't be sent either, it's only logged.
+func reportError(channelID, action string, err error) {
+	fmt.Printf("Failed to %s: %v\n", action, err)
 
-			time.Sleep(time.Millisecond * 300)
-		}
+	if errors.Is(err, ErrUnknownChannel) || errors.Is(err, ErrMissingAccess) {
+		return // Nowhere to report it.
 	}
 
-	return deletedCount
+	sendMessage(channelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to %s: %s```", action, describeError(err)))
 }
 
 func handleMessage(message Message) {
 	fmt.Printf("Starting command handling for message: %s\n", message.Content)
-	content := strings.TrimPrefix(message.Content, config.Prefix)
-	args := strings.Split(content, " ")
+	content := strings.TrimSpace(strings.TrimPrefix(message.Content, currentConfig().Prefix))
 
-	if len(args) == 0 || args[0] == "" {
+	startInvocation(message)
+
+	if content == "" {
 		fmt.Println("Command was empty after parsing")
+		finishInvocation(message, false)
 		return
 	}
 
-	command := args[0]
+	command, line := splitCommand(content)
 
-	if len(args) > 1 {
-		args = args[1:]
-	} else {
-		args = []string{}
-	}
-
-	fmt.Printf("Processing command: %s with args: %v\n", command, args)
+	fmt.Printf("Processing command: %s with args: %s\n", command, line)
 
 	statsMutex.Lock()
 	commandsHandled++
 	statsMutex.Unlock()
 
-	switch command {
-	case "help":
-		fmt.Println("Executing help command...")
-		handleHelp(message)
-	case "categories":
-		fmt.Println("Executing categories command...")
-		handleCategories(message)
-	case "utilities":
-		fmt.Println("Executing utilities command...")
-		handleUtilities(message)
-	case "fun":
-		fmt.Println("Executing fun command...")
-		handleFun(message)
-	case "info":
-		fmt.Println("Executing info command...")
-		handleInfo(message)
-	case "ai":
-		handleAI(message)
-	case "nsfw":
-		fmt.Println("Executing NSFW command...")
-		handleNSFW(message)
-	case "react":
-		fmt.Println("Setting/removing reaction")
-		handleReact(message, args)
-	case "psearch":
-		fmt.Println("Executing pornhubsearch command...")
-		handlePornhubSearch(message, args)
-	case "google":
-		fmt.Println("Executing google...")
-		handleGoogleSearch(message, args)
-	case "tits":
-		fmt.Println("Executing titty command...")
-		handleTits(message)
-	case "catgirl":
-		fmt.Println("Executing titty command...")
-		handleCatgirl(message)
-	case "ping":
-		fmt.Println("Executing ping command...")
-		handlePing(message)
-	case "setprefix":
-		fmt.Println("Executing setprefix command...")
-		handleSetPrefix(message, args)
-	case "say":
-		fmt.Println("Executing say command...")
-		handleSay(message, args)
+	steps := []string{content}
+	if _, ok := lookupCommand(command); !ok {
+		if expansion, ok := lookupAlias(command); ok {
+			steps = expandAlias(expansion, line)
+			fmt.Printf("Expanded alias %s to %q\n", command, steps)
+		}
+	}
+
+	// The steps of an alias run in order, until one of them fails. A command
+	// the alias runs more than once goes on cooldown once, for the whole run.
+	ran, keep := true, false
+	cooled := make(map[*Command]bool)
+	for _, step := range steps {
+		cmd, ok := runCommandLine(message, strings.TrimPrefix(step, currentConfig().Prefix), cooled)
+		if !ok {
+			ran = false
+			break
+		}
+		keep = keep || cmd.KeepMessage
+	}
+
+	// A command that didn't run is left in place, so that it can be fixed by
+	// editing it.
+	if !ran || keep {
+		finishInvocation(message, false)
 		return
-	case "clear":
-		fmt.Println("Executing clear command...")
-		handleClear(message, args)
-	case "avatar":
-		fmt.Println("Executing avatar command...")
-		handleAvatar(message)
-	case "whoami":
-		fmt.Println("Executing whoami command...")
-		handleUserInfo(message)
-	case "femboy":
-		fmt.Println("Executing femboy command...")
-		handleFemboy(message, args)
-	case "8ball":
-		fmt.Println("Executing 8ball command...")
-		handle8Ball(message, args)
-	case "roll":
-		fmt.Println("Executing roll command...")
-		handleRoll(message, args)
-	case "rizz":
-		fmt.Println("Executing rizz command...")
-		handleRizz(message, args)
-	case "ar":
-		fmt.Println("Executing ar command...")
-		handleAutoResponder(message)
-	case "weather":
-		fmt.Println("Executing weather command...")
-		handleWeather(message)
-	case "quote":
-		fmt.Println("Executing quote command...")
-		handleQuote(message)
-	case "stats":
-		fmt.Println("Executing stats command...")
-		handleStats(message)
-	case "credits":
-		fmt.Println("Executing credits command...")
-		handleCredits(message)
-	case "ap":
-		fmt.Println("Executing ap command...")
-		handleAutoPressure(message, args)
-	case "status":
-		fmt.Println("Executing status command...")
-		handleStatus(message, args)
-	case "joke":
-		fmt.Println("Executing joke command...")
-		handleJoke(message)
-	case "urban":
-		fmt.Println("Executing urban command...")
-		handleUrban(message, args)
-	case "coinflip":
-		fmt.Println("Executing coinflip command...")
-		handleCoinFlip(message)
-	case "fact":
-		fmt.Println("Executing fact command...")
-		handleFact(message)
-	case "encode":
-		fmt.Println("Executing encode command...")
-		handleEncode(message, args)
-	case "decode":
-		fmt.Println("Executing decode command...")
-		handleDecode(message, args)
-	case "password":
-		fmt.Println("Executing password command...")
-		handlePassword(message, args)
-	case "ip":
-		fmt.Println("Executing ip command...")
-		handleIPLookup(message, args)
-	case "meme":
-		fmt.Println("Executing meme command...")
-		handleMemePhrase(message)
-	case "shorten":
-		fmt.Println("Executing shorten command...")
-		handleShortenURL(message, args)
-	case "setphrase":
-		fmt.Println("Executing setphrase command...")
-		handleSetPhrase(message, args)
-	default:
-		fmt.Printf("Unknown command: %s\n", command)
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nUnknown command: `%s`. Type %shelp for a list of commands.```", command, config.Prefix))
 	}
 
-	if deleted := deleteMessage(message.ChannelID, message.ID); deleted {
-		fmt.Printf("Deleted command message: %s\n", message.ID)
+	err := deleteMessage(message.ChannelID, message.ID)
+	if err != nil {
+		fmt.Printf("Failed to delete command message %s: %v\n", message.ID, err)
 	} else {
-		fmt.Printf("Failed to delete command message: %s\n", message.ID)
+		fmt.Printf("Deleted command message: %s\n", message.ID)
 	}
+	finishInvocation(message, err == nil)
 
 	fmt.Printf("Command processing completed for: %s\n", command)
 }
 
-func handleHelp(message Message) {
-	helpText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
-		"Commands:\n" +
-		"\u001b[0;32m" + config.Prefix + "help\u001b[0m - Show this help message\n" +
-		"\u001b[0;32m" + config.Prefix + "categories\u001b[0m - Show all command categories\n" +
-		"\u001b[0;32m" + config.Prefix + "utilities\u001b[0m - Show utility commands\n" +
-		"\u001b[0;32m" + config.Prefix + "fun\u001b[0m - Show fun commands\n" +
-		"\u001b[0;32m" + config.Prefix + "info\u001b[0m - Show information commands\n" +
-		"\u001b[0;33m" + config.Prefix + "NSFW\u001b[0m - Not safe for work \n" +
-		"Tip: Type " + config.Prefix + "help <command> for detailed help on a specific command\n" +
-		"```"
-	sendMessage(message.ChannelID, helpText)
+// splitCommand splits a command line, without its prefix, into the command
+// name and the rest of the line.
+func splitCommand(content string) (string, string) {
+	if i := strings.IndexFunc(content, unicode.IsSpace); i >= 0 {
+		return content[:i], content[i:]
+	}
+	return content, ""
 }
 
-func handleCategories(message Message) {
-	categoriesText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
-		"Command Categories:\n\n" +
-		"\u001b[0;33mUtilities\u001b[0m - Usefull stuff\n" +
-		"\u001b[0;33mFun\u001b[0m - Kinda fun commands\n" +
-		"\u001b[0;33mInfo\u001b[0m - Information and statistics\n\n" +
-		"\u001b[0;33mNSFW\u001b[0m - Not safe for work\n\n" +
-		"Use " + config.Prefix + "<category> to see commands in each category\n" +
-		"```"
-	sendMessage(message.ChannelID, categoriesText)
-}
-
-func handleUtilities(message Message) {
-	utilitiesText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
-		"\u001b[0;33mUtility Commands:\u001b[0m\n\n" +
-		"\u001b[0;32m" + config.Prefix + "ping\u001b[0m - Check bot latency\n" +
-		"\u001b[0;32m" + config.Prefix + "clear [count]\u001b[0m - Delete messages (default: 10)\n" +
-		"\u001b[0;32m" + config.Prefix + "weather [location]\u001b[0m - Get current weather (api became payed)\n" +
-		"\u001b[0;32m" + config.Prefix + "ar\u001b[0m - Toggle auto responder\n" +
-		"\u001b[0;32m" + config.Prefix + "ap @user\u001b[0m - Start autopressure on user\n" +
-		"\u001b[0;32m" + config.Prefix + "ap stop\u001b[0m - Stop autopressure\n" +
-		"\u001b[0;32m" + config.Prefix + "status <online|idle|dnd|invisible>\u001b[0m - Change Discord status\n" +
-		"\u001b[0;32m" + config.Prefix + "rpc <on|off|status|set>\u001b[0m - Manage Discord Rich Presence (not working)\n" +
-		"\u001b[0;32m" + config.Prefix + "ip <address>\u001b[0m - Lookup IP information\n" +
-		"\u001b[0;32m" + config.Prefix + "encode <input>\u001b[0m - Encode input to base64\n" +
-		"\u001b[0;32m" + config.Prefix + "decode <base64>\u001b[0m - Decode base64 to text\n" +
-		"\u001b[0;32m" + config.Prefix + "password [length]\u001b[0m - Generate a secure password\n" +
-		"\u001b[0;32m" + config.Prefix + "ai [prompt]\u001b[0m - Get ai results (removed)\n" +
-		"\u001b[0;32m" + config.Prefix + "shorten <url>\u001b[0m - Shorten a URL\n" +
-		"\u001b[0;32m" + config.Prefix + "setprefix [prefix]\u001b[0m - changes prefix\n" +
-		"\u001b[0;32m" + config.Prefix + "cloneserver\u001b[0m - Clone a Discord server (gotta reimplement)\n" +
-		"```"
-	sendMessage(message.ChannelID, utilitiesText)
-}
-
-func handleFun(message Message) {
-	funText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
-		"\u001b[0;33mFun Commands:\u001b[0m\n\n" +
-		"\u001b[0;32m" + config.Prefix + "8ball <question>\u001b[0m - Ask the magic 8ball\n" +
-		"\u001b[0;32m" + config.Prefix + "roll [sides]\u001b[0m - Roll a die (default: 6 sides)\n" +
-		"\u001b[0;32m" + config.Prefix + "rizz\u001b[0m - Get a random pickup line\n" +
-		"\u001b[0;32m" + config.Prefix + "femboy\u001b[0m - femboy percentage of user\n" +
-		"\u001b[0;32m" + config.Prefix + "quote\u001b[0m - Get a random quote\n" +
-		"\u001b[0;32m" + config.Prefix + "joke\u001b[0m - Get a random joke\n" +
-		"\u001b[0;32m" + config.Prefix + "urban <term>\u001b[0m - Look up a term on Urban Dictionary\n" +
-		"\u001b[0;32m" + config.Prefix + "coinflip\u001b[0m - Flip a coin\n" +
-		"\u001b[0;32m" + config.Prefix + "fact\u001b[0m - Get a random fact\n" +
-		"\u001b[0;32m" + config.Prefix + "roast [@user]\u001b[0m - Roast someone\n" +
-		"\u001b[0;32m" + config.Prefix + "dadjoke\u001b[0m - Get a dad joke\n" +
-		"\u001b[0;32m" + config.Prefix + "compliment [@user]\u001b[0m - Compliment someone\n" +
-		"\u001b[0;32m" + config.Prefix + "cat\u001b[0m - Get a random cat picture\n" +
-		"\u001b[0;32m" + config.Prefix + "psearch <term>\u001b[0m - Search PornHub for videos\n" +
-		"\u001b[0;32m" + config.Prefix + "tits\u001b[0m - Get a random tits image\n" +
-		"\u001b[0;32m" + config.Prefix + "meme\u001b[0m - Get a random meme\n" +
-		"```"
-	sendMessage(message.ChannelID, funText)
-}
+// runCommandLine runs one command line, without its prefix, in response to
+// message. It reports whether the command ran, rather than being unknown,
+// given the wrong arguments or on cooldown. Commands in cooled have already
+// had their cooldown checked and started by this invocation, and skip it.
+func runCommandLine(message Message, content string, cooled map[*Command]bool) (*Command, bool) {
+	command, line := splitCommand(content)
 
-func handleNSFW(message Message) {
-	funText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n\n" +
-		"\u001b[0;33mNSFW Commands:\u001b[0m\n\n" +
-		"\u001b[0;32m" + config.Prefix + "psearch <term>\u001b[0m - Search PornHub for videos\n" +
-		"\u001b[0;32m" + config.Prefix + "tits\u001b[0m - Get a random tits image\n" +
-		"\u001b[0;32m" + config.Prefix + "catgirl\u001b[0m - Get a random catgirl image\n" +
-		"```"
-	sendMessage(message.ChannelID, funText)
-}
+	cmd, ok := lookupCommand(command)
+	if !ok {
+		fmt.Printf("Unknown command: %s\n", command)
+		reply(message, unknownCommandText(command))
+		return nil, false
+	}
 
-func handleInfo(message Message) {
-	infoText := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" +
-		"\u001b[0;33mInfo Commands:\u001b[0m\n\n" +
-		"\u001b[0;32m" + config.Prefix + "whoami\u001b[0m - Show your user info\n" +
-		"\u001b[0;32m" + config.Prefix + "avatar\u001b[0m - Get your avatar URL\n" +
-		"\u001b[0;32m" + config.Prefix + "stats\u001b[0m - Show bot statistics\n" +
-		"\u001b[0;32m" + config.Prefix + "credits\u001b[0m - Display bot credits\n" +
-		"```"
-	sendMessage(message.ChannelID, infoText)
+	args, err := parseArgs(cmd, line)
+	if err != nil {
+		sendUsageError(message, err)
+		return cmd, false
+	}
+
+	if !cooled[cmd] {
+		if wait := cmd.startCooldown(); wait > 0 {
+			fmt.Printf("%s is on cooldown for %v\n", cmd.Name, wait)
+			reply(message, cooldownText(cmd, wait))
+			return cmd, false
+		}
+		cooled[cmd] = true
+	}
+
+	// Handlers that read the message see the command they're running, which
+	// for an alias isn't what was typed.
+	message.Content = currentConfig().Prefix + content
+
+	fmt.Printf("Executing %s command...\n", cmd.Name)
+	cmd.Handler(message, args)
+	return cmd, true
 }
 
 func handlePing(message Message) {
-	start := time.Now()
+	gatewayLatency := "n/a"
+	if gateway != nil {
+		if latency := gateway.Latency(); latency > 0 {
+			gatewayLatency = fmt.Sprintf("%dms", latency.Milliseconds())
+		}
+	}
 
-	req, _ := http.NewRequest("GET", "https://discord.com/api/v10/users/@me", nil)
-	req.Header.Set("Authorization", config.Token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	start := time.Now()
 
-	if err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError calculating ping: connection failed```")
+	if err := rest.Do("GET", "/users/@me", nil, nil); err != nil {
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🏓 Pong!\nGateway: %s\nREST: connection failed```", gatewayLatency))
 		return
 	}
-	defer resp.Body.Close()
 
-	latency := time.Since(start).Milliseconds()
+	restLatency := time.Since(start).Milliseconds()
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🏓 Pong! Latency: %dms```", latency))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🏓 Pong!\nGateway: %s\nREST: %dms```", gatewayLatency, restLatency))
 }
 
 func handleAutoResponder(message Message) {
@@ -924,82 +430,55 @@ func handleAutoResponder(message Message) {
 	autoResponderMutex.Unlock()
 
 	fmt.Printf("Auto responder %s\n", status)
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAuto responder %s```", status))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAuto responder %s```", status))
 }
 
 func handleReact(message Message, args []string) {
-    oldEmoji := config.AutoReactEmoji
-    status := ""
-
-    if len(args) == 0 || strings.ToLower(args[0]) == "off" {
-        config.AutoReactEmojiEnabled = false
-        config.AutoReactEmoji = ""
-        status = "disabled"
-    } else {
-        emoji := strings.Join(args, " ")
-        config.AutoReactEmojiEnabled = true
-        config.AutoReactEmoji = emoji
+    enabled, emoji := false, ""
+    status := "disabled"
+
+    if len(args) > 0 && strings.ToLower(args[0]) != "off" {
+        enabled, emoji = true, strings.Join(args, " ")
         status = fmt.Sprintf("enabled with %s", emoji)
     }
 
-    if err := saveConfig(); err != nil {
-        sendMessage(message.ChannelID,
+    err := configStore.Update(func(cfg *Config) error {
+        cfg.AutoReactEmojiEnabled = enabled
+        cfg.AutoReactEmoji = emoji
+        return nil
+    })
+    if err != nil {
+        reply(message,
             fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError saving auto-react config: %s```", err.Error()))
-        config.AutoReactEmoji = oldEmoji
-        config.AutoReactEmojiEnabled = oldEmoji != ""
         return
     }
 
-    sendMessage(message.ChannelID,
+    reply(message,
         fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAuto-react %s```", status))
-
-    fmt.Printf("DEBUG: AutoReactEmojiEnabled=%v, AutoReactEmoji=%s\n",
-        config.AutoReactEmojiEnabled, config.AutoReactEmoji)
 }
 //REST endpoint bla bla bla
 func sendReaction(channelID, messageID, emoji string) {
-    encodedEmoji := url.QueryEscape(emoji)
-    url := fmt.Sprintf(
-        "https://discord.com/api/v10/channels/%s/messages/%s/reactions/%s/@me",
-        channelID, messageID, encodedEmoji,
+    path := fmt.Sprintf(
+        "/channels/%s/messages/%s/reactions/%s/@me",
+        channelID, messageID, url.PathEscape(emoji),
     )
 
-    req, err := http.NewRequest("PUT", url, nil)
-    if err != nil {
-        fmt.Println("Failed to create reaction request:", err)
-        return
-    }
-
-    req.Header.Set("Authorization", config.Token)
-    req.Header.Set("User-Agent", "Mozilla/5.0")
-
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        fmt.Println("Failed to send reaction:", err)
-        return
-    }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != 204 {
-        body, _ := io.ReadAll(resp.Body)
-        fmt.Printf("Reaction failed (emoji %s): %d %s\n", emoji, resp.StatusCode, string(body))
+    if err := rest.Do("PUT", path, nil, nil); err != nil {
+        fmt.Printf("Reaction failed (emoji %s): %v\n", emoji, err)
     }
 }
 
-func handleFemboy(message Message, args []string) {
-	var targetUsername string
+func handleFemboy(message Message, args *Args) {
+	targetUsername := "You"
 
-	if len(message.Mentions) > 0 {
-		targetUsername = message.Mentions[0].Username
-	} else if len(args) > 0 {
-		mentions := extractMentions(message.Content)
-		if len(mentions) > 0 {
-			targetUsername = "user"
-		} else {
-			targetUsername = strings.Join(args, " ")
+	if args.Has("user") {
+		targetUsername = "user"
+		for _, mention := range message.Mentions {
+			if mention.ID == args.String("user") {
+				targetUsername = mention.Username
+				break
+			}
 		}
-	} else {
-		targetUsername = "You"
 	}
 
 	rand.Seed(time.Now().UnixNano())
@@ -1007,12 +486,12 @@ func handleFemboy(message Message, args []string) {
 
 	response := fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s, you are %d%% femboy :3```", targetUsername, percentage)
 
-	sendMessage(message.ChannelID, response)
+	reply(message, response)
 }
 
 func handle8Ball(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease ask a question!```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease ask a question!```")
 		return
 	}
 
@@ -1047,26 +526,24 @@ func handle8Ball(message Message, args []string) {
 		question += "?"
 	}
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s\n\n🎱 %s```", question, response))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s\n\n🎱 %s```", question, response))
 }
 
-func handleRoll(message Message, args []string) {
-	sides := 6
-
-	if len(args) > 0 {
-		if s, err := strconv.Atoi(args[0]); err == nil && s > 0 {
-			sides = s
-		}
-	}
+func handleRoll(message Message, args *Args) {
+	sides := args.Int("sides")
 
 	rand.Seed(time.Now().UnixNano())
 	result := rand.Intn(sides) + 1
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🎲 You rolled a %d (d%d)```", result, sides))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🎲 You rolled a %d (d%d)```", result, sides))
 }
 
 func handleRizz(message Message, args []string) {
-	statusMsgID := sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Fetching rizz line...```")
+	status, err := reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Fetching rizz line...```")
+	if err != nil {
+		reportError(message.ChannelID, "reply to the command", err)
+		return
+	}
 
 	type RizzResponse struct {
 		ID       string `json:"_id"`
@@ -1119,7 +596,7 @@ func handleRizz(message Message, args []string) {
 		line = fallbackLines[rand.Intn(len(fallbackLines))]
 	}
 
-	editMessage(message.ChannelID, statusMsgID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❤️ %s```", line))
+	updateStatus(message.ChannelID, status, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❤️ %s```", line))
 }
 
 func getLocationFromIP() (*IPGeolocation, error) {
@@ -1198,32 +675,33 @@ func getWeatherEmoji(condition string) string {
 	}
 }
 
-func handleWeather(message Message) {
-	args := strings.Fields(message.Content)[1:]
-	location := ""
+func handleWeather(message Message, args *Args) {
+	location := args.String("location")
 
 	statusMsg := "🔄 Fetching weather data"
-	statusMsgID := sendMessage(message.ChannelID, statusMsg)
+	status, err := reply(message, statusMsg)
+	if err != nil {
+		reportError(message.ChannelID, "reply to the command", err)
+		return
+	}
 
-	if len(args) > 0 {
-		location = strings.Join(args, " ")
+	if location != "" {
 		statusMsg = fmt.Sprintf("🔄 Fetching weather for %s", location)
-		editMessage(message.ChannelID, statusMsgID, statusMsg)
+		updateStatus(message.ChannelID, status, statusMsg)
 	}
 
 	var lat, lon float64
 	var locationName string
-	var err error
 
 	if location != "" {
 		locationData, err := getLocationCoordinates(location)
 		if err != nil {
-			editMessage(message.ChannelID, statusMsgID, "❌ Error: "+err.Error())
+			updateStatus(message.ChannelID, status, "❌ Error: "+err.Error())
 			return
 		}
 
 		if len(locationData) == 0 {
-			editMessage(message.ChannelID, statusMsgID, "❌ Location not found")
+			updateStatus(message.ChannelID, status, "❌ Location not found")
 			return
 		}
 
@@ -1237,7 +715,7 @@ func handleWeather(message Message) {
 		ipInfo, err := getUserLocationFromIP()
 		if err != nil {
 			weatherData := getRandomWeather()
-			editMessage(message.ChannelID, statusMsgID, formatWeatherMessage(weatherData, "Random Location"))
+			updateStatusMessage(message.ChannelID, status, NewMessage("").Embed(weatherEmbed(weatherData, "Random Location")))
 			return
 		}
 
@@ -1251,12 +729,11 @@ func handleWeather(message Message) {
 
 	weatherData, err := getWeatherData(lat, lon)
 	if err != nil {
-		editMessage(message.ChannelID, statusMsgID, "❌ Error fetching weather: "+err.Error())
+		updateStatus(message.ChannelID, status, "❌ Error fetching weather: "+err.Error())
 		return
 	}
 
-	weatherMsg := formatWeatherMessage(weatherData, locationName)
-	editMessage(message.ChannelID, statusMsgID, weatherMsg)
+	updateStatusMessage(message.ChannelID, status, NewMessage("").Embed(weatherEmbed(weatherData, locationName)))
 }
 
 type GeocodingResponse []struct {
@@ -1320,7 +797,10 @@ func getUserLocationFromIP() (*IPInfo, error) {
 	return &ipInfo, nil
 }
 
-func formatWeatherMessage(data *WeatherData, location string) string {
+// weatherEmbedColor is the weather card's accent, a sky blue.
+const weatherEmbedColor = 0x5dade2
+
+func weatherEmbed(data *WeatherData, location string) Embed {
 	condition := ""
 	if len(data.Weather) > 0 {
 		condition = data.Weather[0].Main
@@ -1328,18 +808,17 @@ func formatWeatherMessage(data *WeatherData, location string) string {
 
 	emoji := getWeatherEmoji(condition)
 
-	return fmt.Sprintf("**Weather for %s** %s\n\n"+
-		"🌡️ Temperature: **%.1f°C**\n"+
-		"🤔 Feels like: **%.1f°C**\n"+
-		"💧 Humidity: **%d%%**\n"+
-		"💨 Wind: **%.1f m/s**\n"+
-		"🔍 Condition: **%s**",
-		location, emoji,
-		data.Main.Temp,
-		data.Main.FeelsLike,
-		data.Main.Humidity,
-		data.Wind.Speed,
-		strings.Title(strings.ToLower(condition)))
+	return Embed{
+		Title: fmt.Sprintf("%s Weather for %s", emoji, location),
+		Color: weatherEmbedColor,
+		Fields: []EmbedField{
+			{Name: "🌡️ Temperature", Value: fmt.Sprintf("%.1f°C", data.Main.Temp), Inline: true},
+			{Name: "🤔 Feels like", Value: fmt.Sprintf("%.1f°C", data.Main.FeelsLike), Inline: true},
+			{Name: "💧 Humidity", Value: fmt.Sprintf("%d%%", data.Main.Humidity), Inline: true},
+			{Name: "💨 Wind", Value: fmt.Sprintf("%.1f m/s", data.Wind.Speed), Inline: true},
+			{Name: "🔍 Condition", Value: strings.Title(strings.ToLower(condition)), Inline: true},
+		},
+	}
 }
 
 func getRandomWeather() *WeatherData {
@@ -1381,7 +860,7 @@ func handleQuote(message Message) {
 	rand.Seed(time.Now().UnixNano())
 	quote := quotes[rand.Intn(len(quotes))]
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n📜 %s```", quote))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n📜 %s```", quote))
 }
 
 func handleStats(message Message) {
@@ -1395,16 +874,20 @@ func handleStats(message Message) {
 	hours := int(uptime.Hours()) % 24
 	minutes := int(uptime.Minutes()) % 60
 
+	budget := sendBudget.Stats()
+
 	stats := fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n"+
 		"Bot Statistics\n"+
 		"Uptime: %d days, %d hours, %d minutes\n"+
 		"Commands handled: %d\n"+
 		"Messages logged: %d\n"+
-		"Memory usage: %.2f MB```",
+		"Memory usage: %.2f MB\n"+
+		"Send budget: %d/min, burst %d (%d held back)```",
 		days, hours, minutes, cmdHandled, msgLogged,
-		float64(getMemoryUsage())/1024/1024)
+		float64(getMemoryUsage())/1024/1024,
+		budget.PerMinute, budget.Burst, budget.Delayed)
 
-	sendMessage(message.ChannelID, stats)
+	reply(message, stats)
 }
 
 func getMemoryUsage() uint64 {
@@ -1413,40 +896,22 @@ func getMemoryUsage() uint64 {
 	return m.Alloc
 }
 
-func handleSay(message Message, args []string) {
-	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide something to say!```")
-		return
-	}
-
-	content := strings.Join(args, " ")
-
-	sendMessage(message.ChannelID, content)
-}
-
-func handleClear(message Message, args []string) {
-	count := 10
-
-	if len(args) > 0 {
-		if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
-			count = n
-		}
-
-		if count > 100 {
-			count = 100
+func handleSay(message Message, args *Args) {
+	if args.Has("channel") {
+		if _, err := sendMessage(args.String("channel"), args.String("text")); err != nil {
+			reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to send: %s```", describeError(err)))
 		}
+		return
 	}
 
-	deletedCount := deleteMessages(message.ChannelID, count)
-
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🗑️ Deleted %d messages.```", deletedCount))
+	reply(message, args.String("text"))
 }
 
 func handleAvatar(message Message) {
 	avatarURL := fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png?size=1024",
 		message.Author.ID, message.Author.Avatar)
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s```", avatarURL))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s```", avatarURL))
 }
 
 func handleUserInfo(message Message) {
@@ -1458,7 +923,7 @@ func handleUserInfo(message Message) {
 		"Account Created: Unknown```",
 		message.Author.ID, message.Author.Username, message.Author.Bot)
 
-	sendMessage(message.ChannelID, info)
+	reply(message, info)
 }
 
 func handleCredits(message Message) {
@@ -1467,17 +932,14 @@ func handleCredits(message Message) {
 		"Thanks for using RUNE!\n" +
 		"```"
 
-	sendMessage(message.ChannelID, creditsText)
+	reply(message, creditsText)
 }
 
-func handleAutoPressure(message Message, args []string) {
+func handleAutoPressure(message Message, args *Args) {
 	apMutex.Lock()
 	defer apMutex.Unlock()
 
-	fmt.Printf("AP received with args: %v\n", args)
-	fmt.Printf("Message content: %s\n", message.Content)
-
-	if len(args) > 0 && strings.ToLower(args[0]) == "stop" {
+	if args.Bool("stop") {
 		fmt.Println("Stop command detected")
 		if apActive {
 			apActive = false
@@ -1485,33 +947,15 @@ func handleAutoPressure(message Message, args []string) {
 				close(apStopChan)
 				apStopChan = nil
 			}
-			sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAutopressure on <@%s> stopped!```", apTargetID))
+			reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAutopressure on <@%s> stopped!```", apTargetID))
 		} else {
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAutopressure is not active.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAutopressure is not active.```")
 		}
 		return
 	}
 
-	var targetID string
-
-	if len(args) > 0 {
-		if _, err := strconv.ParseUint(args[0], 10, 64); err == nil {
-			fmt.Printf("Using direct user ID: %s\n", args[0])
-			targetID = args[0]
-		}
-	}
-
-	if targetID == "" {
-		mentions := extractMentions(message.Content)
-		fmt.Printf("Extracted mentions: %v\n", mentions)
-
-		if len(mentions) > 0 {
-			targetID = mentions[0]
-		}
-	}
-
-	if targetID == "" {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease mention a user or provide a user ID to start.```")
+	if !args.Has("user") {
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease mention a user or provide a user ID to start, or use --stop to stop.```")
 		return
 	}
 
@@ -1522,20 +966,34 @@ func handleAutoPressure(message Message, args []string) {
 		}
 	}
 
-	apTargetID = targetID
+	apTargetID = args.String("user")
 	apActive = true
 	apStopChan = make(chan bool)
 
+	started := fmt.Sprintf("Autopressure started on <@%s>.", apTargetID)
+	if limit := args.Duration("for"); limit > 0 {
+		started = fmt.Sprintf("Autopressure started on <@%s> for %v.", apTargetID, limit)
+	}
+
 	fmt.Printf("Starting autopressure on user ID: %s\n", apTargetID)
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nAutopressure started on <@%s>.```", apTargetID))
+	reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n"+started+"```")
 
-	go runAutoPressure(message.ChannelID, apTargetID, apStopChan)
+	go runAutoPressure(message.ChannelID, apTargetID, apStopChan, args.Duration("for"))
 }
 
-func runAutoPressure(channelID, targetID string, stopChan chan bool) {
+// runAutoPressure pings targetID in channelID until stopChan is closed, or
+// for limit if it isn't 0.
+func runAutoPressure(channelID, targetID string, stopChan chan bool, limit time.Duration) {
 	initialDelay := 200 * time.Millisecond
 	fallbackDelay := 500 * time.Millisecond
 
+	var timeout <-chan time.Time
+	if limit > 0 {
+		timer := time.NewTimer(limit)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
 	currentDelay := initialDelay
 	ticker := time.NewTicker(currentDelay)
 	defer ticker.Stop()
@@ -1548,6 +1006,15 @@ func runAutoPressure(channelID, targetID string, stopChan chan bool) {
 		select {
 		case <-stopChan:
 			return
+		case <-timeout:
+			apMutex.Lock()
+			// A newer &ap has its own stop channel, and keeps going.
+			if apStopChan == stopChan {
+				apActive = false
+				apStopChan = nil
+			}
+			apMutex.Unlock()
+			return
 		case <-ticker.C:
 			apMutex.Lock()
 			if !apActive {
@@ -1560,9 +1027,7 @@ func runAutoPressure(channelID, targetID string, stopChan chan bool) {
 
 			apMutex.Unlock()
 			triggerTypingAP(channelID)
-			msgID := sendMessage(channelID, message)
-
-			if msgID == "" {
+			if _, err := sendMessage(channelID, message); errors.Is(err, ErrRateLimited) {
 				rateLimitHits++
 
 				if rateLimitHits >= 2 && currentDelay != fallbackDelay {
@@ -1578,28 +1043,10 @@ func runAutoPressure(channelID, targetID string, stopChan chan bool) {
 	}
 }
 
-func extractMentions(content string) []string {
-	fmt.Printf("Extracting mentions from: %s\n", content)
-	var mentions []string
-	mentionRegex := regexp.MustCompile(`<@!?(\d+)>`)
-	matches := mentionRegex.FindAllStringSubmatch(content, -1)
-
-	fmt.Printf("Regex matches: %v\n", matches)
-
-	for _, match := range matches {
-		if len(match) >= 2 {
-			mentions = append(mentions, match[1])
-		}
-	}
-
-	fmt.Printf("Extracted mentions: %v\n", mentions)
-	return mentions
-}
-
 func handleStatus(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(
-			message.ChannelID,
+		reply(
+			message,
 			"```ansi\n\u001b[0;36m[RUNE]\u001b[0m\nPlease provide a status: online, idle, dnd, invisible\nUsage: &status <status> [custom text]```",
 		)
 		return
@@ -1618,8 +1065,8 @@ func handleStatus(message Message, args []string) {
 	case "invisible", "offline":
 		statusText = "invisible"
 	default:
-		sendMessage(
-			message.ChannelID,
+		reply(
+			message,
 			"```ansi\n\u001b[0;36m[RUNE]\u001b[0m\nInvalid status. Use online, idle, dnd, or invisible```",
 		)
 		return
@@ -1631,11 +1078,11 @@ func handleStatus(message Message, args []string) {
 	}
 
 	if err := updateStatusREST(statusText, customText); err != nil {
-		sendMessage(
-			message.ChannelID,
+		reply(
+			message,
 			fmt.Sprintf(
 				"```ansi\n\u001b[0;36m[RUNE]\u001b[0m\nError changing status: %s```",
-				err.Error(),
+				describeError(err),
 			),
 		)
 		return
@@ -1648,8 +1095,8 @@ func handleStatus(message Message, args []string) {
 		responseMsg = fmt.Sprintf("Status updated to %s with text: %s", status, customText)
 	}
 
-	sendMessage(
-		message.ChannelID,
+	reply(
+		message,
 		fmt.Sprintf(
 			"```ansi\n\u001b[0;36m[RUNE]\u001b[0m\n%s```",
 			responseMsg,
@@ -1658,8 +1105,6 @@ func handleStatus(message Message, args []string) {
 }
 
 func updateStatusREST(status string, customText string) error {
-	url := "https://discord.com/api/v10/users/@me/settings"
-
 	payload := map[string]interface{}{
 		"status": status, // online, idle, dnd, invisible
 	}
@@ -1675,29 +1120,8 @@ func updateStatusREST(status string, customText string) error {
 		payload["custom_status"] = nil
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", config.Token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/143.0.0.0 Safari/537.36")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("status update failed (%d): %s", resp.StatusCode, string(b))
+	if err := rest.Do("PATCH", "/users/@me/settings", payload, nil); err != nil {
+		return fmt.Errorf("status update failed: %w", err)
 	}
 
 	return nil
@@ -1706,7 +1130,7 @@ func updateStatusREST(status string, customText string) error {
 func handleJoke(message Message) {
 	resp, err := http.Get("https://v2.jokeapi.dev/joke/Any?blacklistFlags=nsfw,religious,political,racist,sexist,explicit&type=single")
 	if err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError fetching joke```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError fetching joke```")
 		return
 	}
 	defer resp.Body.Close()
@@ -1716,11 +1140,11 @@ func handleJoke(message Message) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&jokeResp); err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError parsing joke```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError parsing joke```")
 		return
 	}
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n😂 %s```", jokeResp.Joke))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n😂 %s```", jokeResp.Joke))
 }
 
 type UrbanDefinition struct {
@@ -1732,7 +1156,7 @@ type UrbanDefinition struct {
 
 func handleUrban(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a term to look up```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a term to look up```")
 		return
 	}
 
@@ -1740,7 +1164,7 @@ func handleUrban(message Message, args []string) {
 
 	if defs, ok := urbanCache[term]; ok {
 		if len(defs) > 0 {
-			formatUrbanDefinition(message.ChannelID, term, defs[0])
+			formatUrbanDefinition(message, term, defs[0])
 			return
 		}
 	}
@@ -1748,7 +1172,7 @@ func handleUrban(message Message, args []string) {
 	apiURL := fmt.Sprintf("https://api.urbandictionary.com/v0/define?term=%s", url.QueryEscape(term))
 	resp, err := http.Get(apiURL)
 	if err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError connecting to Urban Dictionary```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError connecting to Urban Dictionary```")
 		return
 	}
 	defer resp.Body.Close()
@@ -1758,21 +1182,21 @@ func handleUrban(message Message, args []string) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError parsing Urban Dictionary results```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError parsing Urban Dictionary results```")
 		return
 	}
 
 	if len(result.List) == 0 {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nNo definitions found for \"%s\"```", term))
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nNo definitions found for \"%s\"```", term))
 		return
 	}
 
 	urbanCache[term] = result.List
 
-	formatUrbanDefinition(message.ChannelID, term, result.List[0])
+	formatUrbanDefinition(message, term, result.List[0])
 }
 
-func formatUrbanDefinition(channelID, term string, def UrbanDefinition) {
+func formatUrbanDefinition(message Message, term string, def UrbanDefinition) {
 	definition := strings.ReplaceAll(def.Definition, "\r", "")
 	definition = strings.ReplaceAll(definition, "\n", " ")
 
@@ -1795,7 +1219,7 @@ func formatUrbanDefinition(channelID, term string, def UrbanDefinition) {
 		"👍 %d | 👎 %d```",
 		term, definition, example, def.ThumbsUp, def.ThumbsDown)
 
-	sendMessage(channelID, response)
+	reply(message, response)
 }
 
 func handleCoinFlip(message Message) {
@@ -1805,7 +1229,7 @@ func handleCoinFlip(message Message) {
 		result = "Tails"
 	}
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🪙 Coin flip: %s```", result))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🪙 Coin flip: %s```", result))
 }
 
 func handleFact(message Message) {
@@ -1835,35 +1259,35 @@ func handleFact(message Message) {
 	rand.Seed(time.Now().UnixNano())
 	fact := facts[rand.Intn(len(facts))]
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🧠 %s```", fact))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🧠 %s```", fact))
 }
 
 func handleEncode(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide text to encode```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide text to encode```")
 		return
 	}
 
 	text := strings.Join(args, " ")
 	encoded := base64.StdEncoding.EncodeToString([]byte(text))
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔐 Encoded: %s```", encoded))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔐 Encoded: %s```", encoded))
 }
 
 func handleDecode(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide text to decode```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide text to decode```")
 		return
 	}
 
 	text := strings.Join(args, " ")
 	decoded, err := base64.StdEncoding.DecodeString(text)
 	if err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Invalid base64 encoding```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Invalid base64 encoding```")
 		return
 	}
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔓 Decoded: %s```", string(decoded)))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔓 Decoded: %s```", string(decoded)))
 }
 
 func handleMemePhrase(message Message) {
@@ -1890,20 +1314,11 @@ func handleMemePhrase(message Message) {
 
 	phrase := fmt.Sprintf(t, a1, a2)
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n😂 %s```", phrase))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n😂 %s```", phrase))
 }
 
-func handlePassword(message Message, args []string) {
-	length := 16
-
-	if len(args) > 0 {
-		if l, err := strconv.Atoi(args[0]); err == nil && l > 0 {
-			length = l
-			if length > 100 {
-				length = 100
-			}
-		}
-	}
+func handlePassword(message Message, args *Args) {
+	length := args.Int("length")
 
 	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!@#$%^&*()-_=+[]{}|;:,.<>?"
 
@@ -1913,12 +1328,12 @@ func handlePassword(message Message, args []string) {
 		password[i] = chars[rand.Intn(len(chars))]
 	}
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔑 Generated password (%d chars):\n%s```", length, string(password)))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔑 Generated password (%d chars):\n%s```", length, string(password)))
 }
 
 func handleSetPrefix(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nCurrent prefix: %s\nUse '&setprefix off' to disable prefix or '&setprefix !' to set a new symbol prefix```", config.Prefix))
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nCurrent prefix: %s\nUse '&setprefix off' to disable prefix or '&setprefix !' to set a new symbol prefix```", currentConfig().Prefix))
 		return
 	}
 
@@ -1927,46 +1342,90 @@ func handleSetPrefix(message Message, args []string) {
 	if strings.ToLower(newPrefix) == "off" {
 		newPrefix = ""
 	} else if len(newPrefix) != 1 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Prefix must be a single symbol character or 'off'```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Prefix must be a single symbol character or 'off'```")
 		return
 	}
 
-	oldPrefix := config.Prefix
-
-	config.Prefix = newPrefix
-
-	if err := saveConfig(); err != nil {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error saving new prefix: %s```", err.Error()))
-		config.Prefix = oldPrefix
+	var oldPrefix string
+	err := configStore.Update(func(cfg *Config) error {
+		oldPrefix = cfg.Prefix
+		cfg.Prefix = newPrefix
+		return nil
+	})
+	if err != nil {
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error saving new prefix: %s```", err.Error()))
 		return
 	}
 
 	if newPrefix == "" {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Prefix disabled. Commands can now be used without a prefix```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Prefix disabled. Commands can now be used without a prefix```")
 	} else {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Prefix changed from '%s' to '%s'```", oldPrefix, newPrefix))
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Prefix changed from '%s' to '%s'```", oldPrefix, newPrefix))
 	}
 }
 
 func handleSetPhrase(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nCurrent phrase: %s\nUse '&setphrase new phrase' to set a new phrase```", config.AutoResponsePhrase))
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nCurrent phrase: %s\nUse '&setphrase new phrase' to set a new phrase```", currentConfig().AutoResponsePhrase))
 		return
 	}
 
 	ragingDemon := strings.Join(args, " ")
 
-	oldDemon := config.AutoResponsePhrase
+	var oldDemon string
+	err := configStore.Update(func(cfg *Config) error {
+		oldDemon = cfg.AutoResponsePhrase
+		cfg.AutoResponsePhrase = ragingDemon
+		return nil
+	})
+	if err != nil {
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error saving new phrase: %s```", err.Error()))
+		return
+	}
+
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Phrase changed from '%s' to '%s'```", oldDemon, ragingDemon))
+}
+
+func handleFeature(message Message, args []string) {
+	if len(args) < 2 {
+		var list strings.Builder
+		for _, state := range featureStates() {
+			status := "\u001b[0;31moff\u001b[0m"
+			if state.Enabled {
+				status = "\u001b[0;32mon\u001b[0m"
+			}
+			fmt.Fprintf(&list, "%s [%s] - %s\n", state.Name, status, state.Description)
+		}
+
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nFeatures:\n\n%sUsage: %sfeature <name> <on|off>```", list.String(), currentConfig().Prefix))
+		return
+	}
+
+	name := strings.ToLower(args[0])
 
-	config.AutoResponsePhrase = ragingDemon
+	var enabled bool
+	switch strings.ToLower(args[1]) {
+	case "on", "enable":
+		enabled = true
+	case "off", "disable":
+		enabled = false
+	default:
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Use 'on' or 'off'```")
+		return
+	}
 
-	if err := saveConfig(); err != nil {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error saving new phrase: %s```", err.Error()))
-		config.AutoResponsePhrase = oldDemon
+	// Commands can't be switched back on from chat once they are off.
+	if name == "commands" && !enabled {
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ The commands feature can only be disabled from the web UI```")
 		return
 	}
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Phrase changed from '%s' to '%s'```", oldDemon, ragingDemon))
+	if err := setFeatureEnabled(name, enabled); err != nil {
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ %s```", err.Error()))
+		return
+	}
+
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n✅ Feature %s turned %s```", name, args[1]))
 }
 
 func handleIPLookup(message Message, args []string) {
@@ -1975,7 +1434,7 @@ func handleIPLookup(message Message, args []string) {
 	if len(args) == 0 {
 		ipInfo, err := getUserLocationFromIP()
 		if err != nil {
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error getting your IP information```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error getting your IP information```")
 			return
 		}
 
@@ -1988,7 +1447,7 @@ func handleIPLookup(message Message, args []string) {
 	apiURL := fmt.Sprintf("http://ip-api.com/json/%s", url.QueryEscape(ip))
 	resp, err := http.Get(apiURL)
 	if err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error connecting to IP lookup service```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error connecting to IP lookup service```")
 		return
 	}
 	defer resp.Body.Close()
@@ -2011,12 +1470,12 @@ func handleIPLookup(message Message, args []string) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error parsing IP lookup result```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error parsing IP lookup result```")
 		return
 	}
 
 	if result.Status != "success" {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ IP lookup failed for %s```", ip))
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ IP lookup failed for %s```", ip))
 		return
 	}
 
@@ -2036,21 +1495,25 @@ func handleIPLookup(message Message, args []string) {
 		result.Timezone,
 		result.AS)
 
-	sendMessage(message.ChannelID, response)
+	reply(message, response)
 }
 
 func handleTits(message Message) {
-	statusMsgID := sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Finding boobies...```")
+	status, err := reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Finding boobies...```")
+	if err != nil {
+		reportError(message.ChannelID, "reply to the command", err)
+		return
+	}
 
 	resp, err := http.Get("https://api.nekosapi.com/v4/images/random?tags=large_breasts")
 	if err != nil {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to get image: Connection error```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to get image: Connection error```")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		editMessage(message.ChannelID, statusMsgID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ API returned error: %s```", resp.Status))
+		updateStatus(message.ChannelID, status, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ API returned error: %s```", resp.Status))
 		return
 	}
 
@@ -2059,12 +1522,12 @@ func handleTits(message Message) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&images); err != nil {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to parse response```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to parse response```")
 		return
 	}
 
 	if len(images) == 0 {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ No images found```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ No images found```")
 		return
 	}
 
@@ -2072,21 +1535,25 @@ func handleTits(message Message) {
 	randomIndex := rand.Intn(len(images))
 	imageURL := images[randomIndex].URL
 
-	editMessage(message.ChannelID, statusMsgID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🍒 Enjoy:```\n%s", imageURL))
+	updateStatus(message.ChannelID, status, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🍒 Enjoy:```\n%s", imageURL))
 }
 
 func handleCatgirl(message Message) {
-	statusMsgID := sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Finding catgirls...```")
+	status, err := reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Finding catgirls...```")
+	if err != nil {
+		reportError(message.ChannelID, "reply to the command", err)
+		return
+	}
 
 	resp, err := http.Get("https://api.nekosapi.com/v4/images/random?tags=catgirl,large_breasts,exposed_girl_breasts")
 	if err != nil {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to get image: Connection error```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to get image: Connection error```")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		editMessage(message.ChannelID, statusMsgID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ API returned error: %s```", resp.Status))
+		updateStatus(message.ChannelID, status, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ API returned error: %s```", resp.Status))
 		return
 	}
 
@@ -2095,12 +1562,12 @@ func handleCatgirl(message Message) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&images); err != nil {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to parse response```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to parse response```")
 		return
 	}
 
 	if len(images) == 0 {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ No images found```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ No images found```")
 		return
 	}
 
@@ -2108,12 +1575,12 @@ func handleCatgirl(message Message) {
 	randomIndex := rand.Intn(len(images))
 	imageURL := images[randomIndex].URL
 
-	editMessage(message.ChannelID, statusMsgID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🍒 Enjoy:```\n%s", imageURL))
+	updateStatus(message.ChannelID, status, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🍒 Enjoy:```\n%s", imageURL))
 }
 
 func handlePornhubSearch(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a search term!```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a search term!```")
 		return
 	}
 
@@ -2121,12 +1588,12 @@ func handlePornhubSearch(message Message, args []string) {
 
 	pornhubURL := fmt.Sprintf("https://www.pornhub.com/video/search?search=%s", searchQuery)
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔍 PornHub Search:```\n%s", pornhubURL))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔍 PornHub Search:```\n%s", pornhubURL))
 }
 
 func handleGoogleSearch(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a search term!```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a search term!```")
 		return
 	}
 
@@ -2134,51 +1601,50 @@ func handleGoogleSearch(message Message, args []string) {
 
 	googleURL := fmt.Sprintf("https://www.google.com/search?q=%s", searchQuery)
 
-	sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔍 Google Search:```\n%s", googleURL))
+	reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔍 Google Search:```\n%s", googleURL))
 }
 
-func handleShortenURL(message Message, args []string) {
-	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a URL to shorten```")
+func handleShortenURL(message Message, args *Args) {
+	longURL := args.URL("url").String()
+
+	status, err := reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Shortening URL...```")
+	if err != nil {
+		reportError(message.ChannelID, "reply to the command", err)
 		return
 	}
 
-	longURL := args[0]
-
-	statusMsgID := sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Shortening URL...```")
-
 	apiURL := fmt.Sprintf("https://tinyurl.com/api-create.php?url=%s", url.QueryEscape(longURL))
 	resp, err := http.Get(apiURL)
 	if err != nil {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error connecting to URL shortener```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Error connecting to URL shortener```")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to shorten URL: service error```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to shorten URL: service error```")
 		return
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		editMessage(message.ChannelID, statusMsgID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to read response```")
+		updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n❌ Failed to read response```")
 		return
 	}
 
 	shortURL := string(body)
 
-	editMessage(message.ChannelID, statusMsgID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔗 Shortened URL:```\n%s", shortURL))
+	updateStatus(message.ChannelID, status, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔗 Shortened URL:```\n%s", shortURL))
 }
 
 func handleAI(message Message) {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nFunction removed due to gemini being a gay retard.```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nFunction removed due to gemini being a gay retard.```")
 		return
 }
 
 /* func handleAI(message Message, args []string) {
 	if len(args) == 0 {
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a prompt for the AI to respond to.```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nPlease provide a prompt for the AI to respond to.```")
 		return
 	}
 
@@ -2191,7 +1657,7 @@ func handleAI(message Message) {
 	})
 	if err != nil {
 		log.Printf("Error initializing Gemini API client: %v", err)
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError initializing AI client.```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError initializing AI client.```")
 		return
 	}
 
@@ -2203,7 +1669,7 @@ func handleAI(message Message) {
 	)
 	if err != nil {
 		log.Printf("Error generating AI response: %v", err)
-		sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError generating AI response.```")
+		reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError generating AI response.```")
 		return
 	}
 
@@ -2214,14 +1680,14 @@ func handleAI(message Message) {
 		err := os.WriteFile(tempFile, []byte(response), 0644)
 		if err != nil {
 			log.Printf("Error writing response to file: %v", err)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Response too long and couldn't save to file.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Response too long and couldn't save to file.```")
 			return
 		}
 
 		file, err := os.Open(tempFile)
 		if err != nil {
 			log.Printf("Error opening response file: %v", err)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't read response file.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't read response file.```")
 			return
 		}
 		defer file.Close()
@@ -2233,13 +1699,13 @@ func handleAI(message Message) {
 		part, err := writer.CreateFormFile("file", tempFile)
 		if err != nil {
 			log.Printf("Error creating form file: %v", err)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't prepare file for upload.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't prepare file for upload.```")
 			return
 		}
 		_, err = io.Copy(part, file)
 		if err != nil {
 			log.Printf("Error copying file to request: %v", err)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't prepare file content.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't prepare file content.```")
 			return
 		}
 		writer.Close()
@@ -2247,18 +1713,18 @@ func handleAI(message Message) {
 		req, err := http.NewRequest("POST", url, body)
 		if err != nil {
 			log.Printf("Error creating upload request: %v", err)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't create upload request.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't create upload request.```")
 			return
 		}
 
 		req.Header.Set("Content-Type", writer.FormDataContentType())
-		req.Header.Set("Authorization", config.Token)
+		req.Header.Set("Authorization", currentConfig().Token)
 
 		client := &http.Client{}
 		resp, err := client.Do(req)
 		if err != nil {
 			log.Printf("Error uploading file: %v", err)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't upload response file.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't upload response file.```")
 			return
 		}
 		defer resp.Body.Close()
@@ -2267,53 +1733,92 @@ func handleAI(message Message) {
 
 		if resp.StatusCode != http.StatusOK {
 			log.Printf("Error response from Discord: %v", resp.Status)
-			sendMessage(message.ChannelID, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't send response file.```")
+			reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nError: Couldn't send response file.```")
 			return
 		}
 	} else {
-		sendMessage(message.ChannelID, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s```", response))
+		reply(message, fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n%s```", response))
 	}
 } */
 
-func saveConfig() error {
-	configData, err := json.MarshalIndent(config, "", "    ")
+func main() {
+	path := configPath
+	if env, ok := os.LookupEnv("RUNE_CONFIG"); ok {
+		path = env
+	}
+
+	secretsPath := os.Getenv("RUNE_SECRETS")
+
+	var overrides ConfigOverrides
+	checkOnly := flag.Bool("check-config", false, "validate the config and exit")
+	encrypt := flag.Bool("encrypt-secrets", false, "move the token and API keys from the config into the encrypted secrets file and exit")
+	flag.StringVar(&path, "config", path, "path to the config file (also RUNE_CONFIG)")
+	flag.StringVar(&secretsPath, "secrets", secretsPath, "path to the encrypted secrets file, by default next to the config file (also RUNE_SECRETS)")
+	registerConfigFlags(flag.CommandLine, &overrides)
+	flag.Parse()
+
+	if err := readEnvOverrides(&overrides); err != nil {
+		printConfigError("The environment", err)
+		os.Exit(1)
+	}
+
+	if secretsPath == "" {
+		secretsPath = secretsPathFor(path)
+	}
+	secrets, err := loadSecrets(secretsPath)
 	if err != nil {
-		return fmt.Errorf("error marshaling config: %w", err)
+		fmt.Println("Error reading secrets:", err)
+		os.Exit(1)
 	}
 
-	if err := os.WriteFile("config.json", configData, 0644); err != nil {
-		return fmt.Errorf("error writing config file: %w", err)
+	// Secrets are laid over the config file, and the environment and flags
+	// over them.
+	configStore.SetSource(path, func(cfg *Config) {
+		secrets.apply(cfg)
+		overrides.apply(cfg)
+	})
+
+	if *checkOnly {
+		os.Exit(checkConfig())
 	}
 
-	return nil
-}
+	loadConfig()
+	if *encrypt {
+		os.Exit(moveSecrets(secretsPath, secrets))
+	}
+	if _, inSecrets := secrets.values["token"]; !inSecrets && !slices.Contains(overrides.keys(), "token") {
+		fmt.Printf("Your token is stored in plain text in %s, run with --encrypt-secrets to encrypt it\n", path)
+	}
 
-func main() {
-	fmt.Println("Starting...")
-	fmt.Printf("Using token: %s...\n", config.Token[:15])
-	fmt.Printf("Owner ID: %s\n", config.OwnerID)
-	fmt.Printf("Command prefix: %s\n", config.Prefix)
+	restoreOutput := redactOutput()
+	defer restoreOutput()
 
-	if err := connectWebsocket(); err != nil {
-		fmt.Printf("Error connecting to gateway: %v\n", err)
-		os.Exit(1)
+	rand.Seed(time.Now().UnixNano())
+	registerEventHandlers()
+	watchConfig(path)
+
+	fmt.Println("Starting...")
+	fmt.Printf("Owner ID: %s\n", currentConfig().OwnerID)
+	fmt.Printf("Command prefix: %s\n", currentConfig().Prefix)
+	if keys := overrides.keys(); len(keys) > 0 {
+		fmt.Printf("Overridden by the environment or command line: %s\n", strings.Join(keys, ", "))
 	}
 
+	gateway = NewGateway(handleDispatch)
+	gateway.Start()
+
 	fmt.Println("Running. Press Ctrl+C to exit.")
-	go listenForMessages()
-	go StartUIServer("8080")
+	go StartUIServer(strconv.Itoa(currentConfig().UIPort))
 
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
-
-	if heartbeatTicker != nil {
-		heartbeatTicker.Stop()
-	}
 
-	if wsConn != nil {
-		wsConn.Close()
+	select {
+	case <-sc:
+	case <-gateway.Done():
 	}
 
 	fmt.Println("Shutting down...")
+	gateway.Close()
+	bus.Close()
 }
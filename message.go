@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMessageLength is the most characters Discord accepts in a message's
+// content.
+const maxMessageLength = 2000
+
+// Embed is a rich embed attached to a message.
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	Timestamp   string       `json:"timestamp,omitempty"`
+	Footer      *EmbedFooter `json:"footer,omitempty"`
+	Image       *EmbedMedia  `json:"image,omitempty"`
+	Thumbnail   *EmbedMedia  `json:"thumbnail,omitempty"`
+	Author      *EmbedAuthor `json:"author,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+}
+
+type EmbedFooter struct {
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+type EmbedMedia struct {
+	URL string `json:"url"`
+}
+
+type EmbedAuthor struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// AllowedMentions controls who a message pings. The zero value pings nobody:
+// Discord parses no mentions when parse is left out.
+type AllowedMentions struct {
+	Parse       []string `json:"parse,omitempty"` // Any of "users", "roles" and "everyone".
+	Users       []string `json:"users,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	RepliedUser bool     `json:"replied_user"`
+}
+
+// MessageReference points a reply at the message it answers.
+type MessageReference struct {
+	MessageID       string `json:"message_id"`
+	ChannelID       string `json:"channel_id,omitempty"`
+	GuildID         string `json:"guild_id,omitempty"`
+	FailIfNotExists bool   `json:"fail_if_not_exists"`
+}
+
+type messageAttachment struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
+}
+
+// MessageSend is a message to send with postMessage. Build one with
+// NewMessage and its chainable setters:
+//
+//	postMessage(channelID, NewMessage("done").ReplyTo(message).File("log.txt", data))
+type MessageSend struct {
+	Content          string              `json:"content,omitempty"`
+	Embeds           []Embed             `json:"embeds,omitempty"`
+	MessageReference *MessageReference   `json:"message_reference,omitempty"`
+	AllowedMentions  *AllowedMentions    `json:"allowed_mentions,omitempty"`
+	StickerIDs       []string            `json:"sticker_ids,omitempty"`
+	Attachments      []messageAttachment `json:"attachments,omitempty"`
+
+	files []File
+}
+
+func NewMessage(content string) *MessageSend {
+	return &MessageSend{Content: content}
+}
+
+func (m *MessageSend) Embed(embeds ...Embed) *MessageSend {
+	m.Embeds = append(m.Embeds, embeds...)
+	return m
+}
+
+// ReplyTo makes the message a reply to message. It fails quietly, sending a
+// normal message, if message has been deleted.
+func (m *MessageSend) ReplyTo(message Message) *MessageSend {
+	m.MessageReference = &MessageReference{
+		MessageID: message.ID,
+		ChannelID: message.ChannelID,
+		GuildID:   message.GuildID,
+	}
+	return m
+}
+
+func (m *MessageSend) Mentions(allowed AllowedMentions) *MessageSend {
+	m.AllowedMentions = &allowed
+	return m
+}
+
+// NoMentions stops the message pinging anyone, including a replied-to user.
+func (m *MessageSend) NoMentions() *MessageSend {
+	return m.Mentions(AllowedMentions{})
+}
+
+func (m *MessageSend) Stickers(ids ...string) *MessageSend {
+	m.StickerIDs = append(m.StickerIDs, ids...)
+	return m
+}
+
+// File attaches data as a file called name.
+func (m *MessageSend) File(name string, data []byte) *MessageSend {
+	m.Attachments = append(m.Attachments, messageAttachment{ID: len(m.files), Filename: name})
+	m.files = append(m.files, File{Name: name, Data: data})
+	return m
+}
+
+// postMessage sends msg to channelID, as a multipart upload if it has files.
+func postMessage(channelID string, msg *MessageSend) (Message, error) {
+	fmt.Printf("Attempting to send message to channel %s: %s\n", channelID, msg.Content)
+
+	path := fmt.Sprintf("/channels/%s/messages", channelID)
+
+	var sent Message
+	var err error
+	if len(msg.files) > 0 {
+		err = rest.DoMultipart("POST", path, msg, msg.files, &sent)
+	} else {
+		err = rest.Do("POST", path, msg, &sent)
+	}
+
+	if err != nil {
+		fmt.Println("Error sending message:", err)
+		return Message{}, err
+	}
+
+	fmt.Printf("Message sent successfully to channel %s\n", channelID)
+	return sent, nil
+}
+
+// patchMessage edits messageID in channelID to match msg, replacing its
+// content and embeds. msg's files are ignored, since an edit can't swap them.
+func patchMessage(channelID, messageID string, msg *MessageSend) (Message, error) {
+	embeds := msg.Embeds
+	if embeds == nil {
+		embeds = []Embed{} // Clears any embeds the message had.
+	}
+
+	body := map[string]any{
+		"content": msg.Content,
+		"embeds":  embeds,
+	}
+	if msg.AllowedMentions != nil {
+		body["allowed_mentions"] = msg.AllowedMentions
+	}
+
+	var edited Message
+	if err := rest.Do("PATCH", fmt.Sprintf("/channels/%s/messages/%s", channelID, messageID), body, &edited); err != nil {
+		fmt.Println("Error editing message:", err)
+		return Message{}, err
+	}
+
+	return edited, nil
+}
+
+// splitContent prepares content for sending when it may be over Discord's
+// length limit. It returns the content for the first message and any messages
+// to send after it: the rest of the content split over as many messages as
+// it takes, or a .txt file of it if long_messages_as_file is set in the config.
+func splitContent(content string) (string, []*MessageSend) {
+	if utf8.RuneCountInString(content) <= maxMessageLength {
+		return content, nil
+	}
+
+	if currentConfig().LongMessagesAsFile {
+		return "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nOutput too long, attached as a file.```",
+			[]*MessageSend{NewMessage("").File("output.txt", []byte(plainText(content)))}
+	}
+
+	chunks := splitMessage(content, maxMessageLength)
+
+	followUps := make([]*MessageSend, 0, len(chunks)-1)
+	for _, chunk := range chunks[1:] {
+		followUps = append(followUps, NewMessage(chunk))
+	}
+
+	return chunks[0], followUps
+}
+
+// sendFollowUps sends the messages splitContent returned after the first.
+func sendFollowUps(channelID string, msgs []*MessageSend) error {
+	for _, msg := range msgs {
+		if _, err := postMessage(channelID, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitMessage splits content into chunks of at most limit characters,
+// breaking between lines where it can. A code block that's open at a break
+// is closed at the end of the chunk and re-opened, with the same language,
+// at the start of the next.
+func splitMessage(content string, limit int) []string {
+	var chunks []string
+	var fence codeFence
+	var chunk strings.Builder
+
+	flush := func() {
+		text := chunk.String()
+		if fence.open {
+			text += "```"
+		}
+		chunks = append(chunks, text)
+
+		chunk.Reset()
+		if fence.open {
+			chunk.WriteString("```" + fence.lang + "\n")
+		}
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		for line != "" {
+			after := fence
+			after.scan(line)
+
+			used := utf8.RuneCountInString(chunk.String())
+			if used+utf8.RuneCountInString(line)+after.closeLength() <= limit {
+				chunk.WriteString(line)
+				fence = after
+				break
+			}
+
+			if used > fence.openLength() {
+				flush()
+				continue
+			}
+
+			// The line doesn't fit even in an empty chunk, so it has to be
+			// broken up. Leave room to close a code block, but always take
+			// at least a character, even when the limit is too small for
+			// the re-opened block, so the split moves on.
+			piece := truncateRunes(line, max(limit-used-len("```"), 1))
+			chunk.WriteString(piece)
+			fence.scan(piece)
+			line = line[len(piece):]
+			flush()
+		}
+	}
+
+	if chunk.Len() > fence.openLength() || len(chunks) == 0 {
+		flush()
+	}
+
+	return chunks
+}
+
+// codeFence tracks whether a ``` code block is open, and its language.
+type codeFence struct {
+	open bool
+	lang string
+}
+
+func (f *codeFence) scan(s string) {
+	for {
+		i := strings.Index(s, "```")
+		if i < 0 {
+			return
+		}
+		s = s[i+len("```"):]
+
+		if f.open {
+			f.open, f.lang = false, ""
+			continue
+		}
+
+		end := strings.IndexAny(s, " `\n")
+		if end < 0 {
+			end = len(s)
+		}
+		f.open, f.lang = true, s[:end]
+	}
+}
+
+func (f codeFence) openLength() int {
+	if !f.open {
+		return 0
+	}
+	return utf8.RuneCountInString("```" + f.lang + "\n")
+}
+
+func (f codeFence) closeLength() int {
+	if !f.open {
+		return 0
+	}
+	return len("```")
+}
+
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// plainText strips the ANSI colours and code fences from content, for output
+// that's attached as a file.
+func plainText(content string) string {
+	content = ansiEscape.ReplaceAllString(content, "")
+	content = strings.ReplaceAll(content, "```ansi", "\n")
+	content = strings.ReplaceAll(content, "```", "\n")
+	content = blankLines.ReplaceAllString(content, "\n\n")
+	return strings.TrimSpace(content) + "\n"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSplitMessageShort(t *testing.T) {
+	content := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nhello```"
+
+	if chunks := splitMessage(content, maxMessageLength); len(chunks) != 1 || chunks[0] != content {
+		t.Errorf("got %q", chunks)
+	}
+}
+
+func TestSplitMessageReopensFence(t *testing.T) {
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, strings.Repeat("x", 20))
+	}
+	content := "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n" + strings.Join(lines, "\n") + "```"
+
+	chunks := splitMessage(content, maxMessageLength)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+
+	var joined strings.Builder
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > maxMessageLength {
+			t.Errorf("chunk %d is %d characters", i, n)
+		}
+		if strings.Count(chunk, "```")%2 != 0 {
+			t.Errorf("chunk %d leaves a code block open: %q", i, chunk)
+		}
+		if i > 0 && !strings.HasPrefix(chunk, "```ansi\n") {
+			t.Errorf("chunk %d doesn't re-open the ansi block: %q", i, chunk[:20])
+		}
+
+		chunk = strings.TrimPrefix(chunk, "```ansi\n")
+		chunk = strings.TrimSuffix(chunk, "```")
+		joined.WriteString(chunk)
+	}
+
+	// Every line survives whole and in order.
+	if got := strings.Count(joined.String(), strings.Repeat("x", 20)); got != len(lines) {
+		t.Errorf("got %d lines back, want %d", got, len(lines))
+	}
+}
+
+func TestSplitMessageLongLine(t *testing.T) {
+	content := strings.Repeat("é", 4500)
+
+	chunks := splitMessage(content, maxMessageLength)
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	if strings.Join(chunks, "") != content {
+		t.Error("chunks don't join back into the content")
+	}
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk %d splits a character", i)
+		}
+	}
+}
+
+func TestSplitMessageTinyLimit(t *testing.T) {
+	// Limits that leave no room beside a code block's fences still get
+	// through the content, a character at a time.
+	done := make(chan []string)
+	go func() {
+		done <- splitMessage("hello", 3)
+		splitMessage("```ansi\nhello```", 11)
+		close(done)
+	}()
+
+	select {
+	case chunks := <-done:
+		if strings.Join(chunks, "") != "hello" {
+			t.Errorf("got %q", chunks)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the split never finished")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the split with a code block never finished")
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := plainText("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\nhello```")
+	if got != "[RUNE]\n\nhello\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUpdateStatusReportsFailures(t *testing.T) {
+	var mu sync.Mutex
+	var posted []string
+
+	defaultREST := rest
+	rest = newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"Missing Permissions","code":50013}`)
+		case http.MethodPost:
+			var body struct {
+				Content string `json:"content"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+
+			mu.Lock()
+			posted = append(posted, body.Content)
+			mu.Unlock()
+			io.WriteString(w, `{"id":"333333333333333333"}`)
+		}
+	})
+	t.Cleanup(func() { rest = defaultREST })
+
+	updateStatus("111111111111111111", Message{ID: "222222222222222222"}, "done")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(posted) != 1 || !strings.Contains(posted[0], "Failed to update the response: Missing permissions") {
+		t.Errorf("posted %q", posted)
+	}
+}
+
+func TestAllowedMentionsOmitsEmptyParse(t *testing.T) {
+	tests := []struct {
+		allowed AllowedMentions
+		want    string
+	}{
+		{AllowedMentions{}, `{"replied_user":false}`},
+		{AllowedMentions{Parse: []string{"users"}, RepliedUser: true}, `{"parse":["users"],"replied_user":true}`},
+	}
+
+	for _, test := range tests {
+		data, err := json.Marshal(test.allowed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.want {
+			t.Errorf("got %s, want %s", data, test.want)
+		}
+	}
+}
+
+func TestUpdateStatusMessageSendsEmbed(t *testing.T) {
+	var body struct {
+		Content string  `json:"content"`
+		Embeds  []Embed `json:"embeds"`
+	}
+
+	defaultREST := rest
+	rest = newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/channels/111111111111111111/messages/222222222222222222" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		io.WriteString(w, `{"id":"222222222222222222"}`)
+	})
+	t.Cleanup(func() { rest = defaultREST })
+
+	data := &WeatherData{}
+	data.Main.Temp = 21.5
+	data.Weather = append(data.Weather, struct {
+		Main        string `json:"main"`
+		Description string `json:"description"`
+	}{Main: "Rain"})
+
+	updateStatusMessage("111111111111111111", Message{ID: "222222222222222222"}, NewMessage("").Embed(weatherEmbed(data, "Oslo, NO")))
+
+	if body.Content != "" || len(body.Embeds) != 1 {
+		t.Fatalf("got %+v", body)
+	}
+	embed := body.Embeds[0]
+	if embed.Title != "🌧️ Weather for Oslo, NO" || len(embed.Fields) != 5 || embed.Fields[0].Value != "21.5°C" || embed.Fields[4].Value != "Rain" {
+		t.Errorf("got embed %+v", embed)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+func termios(fd uintptr, request uintptr, state *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(state)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// readPassphrase asks for a passphrase on the terminal, without echoing
+// what's typed.
+func readPassphrase(prompt string) (string, error) {
+	fd := os.Stdin.Fd()
+
+	var state syscall.Termios
+	if err := termios(fd, syscall.TCGETS, &state); err != nil {
+		return "", errors.New("there's no terminal to ask for the passphrase on, set RUNE_PASSPHRASE")
+	}
+
+	noEcho := state
+	noEcho.Lflag &^= syscall.ECHO
+	if err := termios(fd, syscall.TCSETS, &noEcho); err != nil {
+		return "", err
+	}
+	defer termios(fd, syscall.TCSETS, &state)
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
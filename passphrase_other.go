@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readPassphrase asks for a passphrase on the terminal. It can't turn echo
+// off here, so what's typed shows; RUNE_PASSPHRASE avoids that.
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, "(the passphrase will show as you type it) "+prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
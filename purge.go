@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// discordEpoch is the first millisecond of 2015, which snowflakes count
+	// from.
+	discordEpoch = 1420070400000
+
+	purgePageSize     = 100
+	purgePreviewLines = 10
+)
+
+var (
+	selfMutex sync.RWMutex
+	selfUser  User
+)
+
+// selfID returns the ID of the account the gateway is logged in as, or ""
+// before the first READY.
+func selfID() string {
+	selfMutex.RLock()
+	defer selfMutex.RUnlock()
+
+	return selfUser.ID
+}
+
+func setSelfUser(user User) {
+	selfMutex.Lock()
+	selfUser = user
+	selfMutex.Unlock()
+}
+
+// purgeOptions are &clear's filters. before and after are exclusive snowflake
+// bounds, or 0 when unset.
+type purgeOptions struct {
+	count           int
+	contains        string
+	before          uint64
+	after           uint64
+	attachmentsOnly bool
+	dryRun          bool
+}
+
+// purgeOptionsFrom reads &clear's filters from its parsed arguments.
+func purgeOptionsFrom(args *Args) (purgeOptions, error) {
+	opts := purgeOptions{
+		count:           args.Int("count"),
+		contains:        strings.ToLower(args.String("contains")),
+		attachmentsOnly: args.Bool("attachments-only"),
+		dryRun:          args.Bool("dry-run"),
+	}
+
+	for _, bound := range []struct {
+		flag  string
+		value *uint64
+	}{{"before", &opts.before}, {"after", &opts.after}} {
+		if !args.Has(bound.flag) {
+			continue
+		}
+
+		var err error
+		if *bound.value, err = parseSnowflakeOrDate(args.String(bound.flag)); err != nil {
+			return opts, fmt.Errorf("--%s: %w", bound.flag, err)
+		}
+	}
+
+	if opts.before != 0 && opts.after != 0 && opts.after >= opts.before {
+		return opts, errors.New("--after has to be earlier than --before")
+	}
+
+	return opts, nil
+}
+
+// parseSnowflakeOrDate reads a message ID, or a date that's turned into the
+// first snowflake of that moment.
+func parseSnowflakeOrDate(s string) (uint64, error) {
+	if isSnowflake(s) {
+		return strconv.ParseUint(s, 10, 64)
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return snowflakeAt(t), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%q is neither a message ID nor a date like 2025-01-31", s)
+}
+
+func snowflakeAt(t time.Time) uint64 {
+	ms := t.UnixMilli() - discordEpoch
+	if ms < 0 {
+		return 0
+	}
+	return uint64(ms) << 22
+}
+
+func (opts purgeOptions) matches(message Message, self string) bool {
+	if message.Author.ID != self {
+		return false
+	}
+	if opts.attachmentsOnly && len(message.Attachments) == 0 {
+		return false
+	}
+	if opts.contains != "" && !strings.Contains(strings.ToLower(message.Content), opts.contains) {
+		return false
+	}
+	return true
+}
+
+// purgeResult is what a purge did, or in a dry run would have done.
+type purgeResult struct {
+	scanned int
+	matched []Message
+	deleted int
+	failed  int
+}
+
+// done is how many messages count towards the purge's limit: those deleted,
+// or in a dry run those that would have been.
+func (result purgeResult) done(opts purgeOptions) int {
+	if opts.dryRun {
+		return len(result.matched)
+	}
+	return result.deleted
+}
+
+// purgeMessages walks channelID's history backwards from before, a page at a
+// time, and deletes the account's own messages that match opts until
+// opts.count have been deleted, the history runs out or opts.after is
+// reached. progress is called after each page.
+func purgeMessages(channelID string, before uint64, opts purgeOptions, progress func(purgeResult)) (purgeResult, error) {
+	var result purgeResult
+
+	self := selfID()
+
+	for {
+		var page []Message
+		path := fmt.Sprintf("/channels/%s/messages?limit=%d&before=%d", channelID, purgePageSize, before)
+		if err := rest.Do("GET", path, nil, &page); err != nil {
+			return result, err
+		}
+
+		for _, message := range page {
+			id, err := strconv.ParseUint(message.ID, 10, 64)
+			if err != nil {
+				continue
+			}
+			before = id
+
+			if opts.after != 0 && id <= opts.after {
+				return result, nil
+			}
+
+			result.scanned++
+			if !opts.matches(message, self) {
+				continue
+			}
+
+			result.matched = append(result.matched, message)
+
+			if !opts.dryRun {
+				if err := deleteMessage(channelID, message.ID); err != nil {
+					if errors.Is(err, ErrRateLimited) {
+						return result, err
+					}
+					if !errors.Is(err, ErrUnknownMessage) {
+						fmt.Printf("Failed to delete message %s: %v\n", message.ID, err)
+						result.failed++
+						continue
+					}
+				}
+				result.deleted++
+			}
+
+			if result.done(opts) >= opts.count {
+				return result, nil
+			}
+		}
+
+		if len(page) < purgePageSize {
+			return result, nil
+		}
+
+		progress(result)
+	}
+}
+
+func handleClear(message Message, args *Args) {
+	opts, err := purgeOptionsFrom(args)
+	if err != nil {
+		command, _ := lookupCommand("clear")
+		sendUsageError(message, &UsageError{command, err.Error()})
+		return
+	}
+
+	if selfID() == "" {
+		setSelfUser(message.Author) // Commands only come from the account itself.
+	}
+
+	before := opts.before
+	if commandID, err := strconv.ParseUint(message.ID, 10, 64); err == nil && (before == 0 || commandID < before) {
+		before = commandID
+	}
+
+	status, err := reply(message, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 Scanning messages...```")
+	if err != nil {
+		reportError(message.ChannelID, "reply to the command", err)
+		return
+	}
+
+	verb := "Deleted"
+	if opts.dryRun {
+		verb = "Found"
+	}
+
+	result, err := purgeMessages(message.ChannelID, before, opts, func(result purgeResult) {
+		// A missed progress update isn't worth stopping for; the final report
+		// is checked below.
+		progress := fmt.Sprintf("```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n🔄 %s %d of %d (scanned %d)...```", verb, result.done(opts), opts.count, result.scanned)
+		if _, err := editMessage(message.ChannelID, status.ID, progress); err != nil {
+			fmt.Printf("Failed to update clear progress: %v\n", err)
+		}
+	})
+
+	var report strings.Builder
+	if opts.dryRun {
+		fmt.Fprintf(&report, "🔍 Would delete %d messages (scanned %d)", len(result.matched), result.scanned)
+		for i, matched := range result.matched {
+			if i == purgePreviewLines {
+				fmt.Fprintf(&report, "\n...and %d more", len(result.matched)-i)
+				break
+			}
+			fmt.Fprintf(&report, "\n%s %s", formatMessageTime(matched.Timestamp), previewContent(matched))
+		}
+	} else {
+		fmt.Fprintf(&report, "🗑️ Deleted %d messages (scanned %d)", result.deleted, result.scanned)
+		if result.failed > 0 {
+			fmt.Fprintf(&report, "\n%d couldn't be deleted", result.failed)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Clear stopped early: %v\n", err)
+		fmt.Fprintf(&report, "\n❌ Stopped early: %s", describeError(err))
+	}
+
+	updateStatus(message.ChannelID, status, "```ansi\n\u001b[0;36m[RUNE]\u001b[0m``````ansi\n"+report.String()+"```")
+}
+
+func formatMessageTime(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func previewContent(message Message) string {
+	content := strings.ReplaceAll(message.Content, "```", "'''")
+	content = strings.ReplaceAll(content, "\n", " ")
+	if short := truncateRunes(content, 60); short != content {
+		content = short + "…"
+	}
+	if len(message.Attachments) > 0 {
+		content += fmt.Sprintf(" [%d attachments]", len(message.Attachments))
+	}
+	return content
+}
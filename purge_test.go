@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPurgeOptionsFrom(t *testing.T) {
+	command, _ := lookupCommand("clear")
+
+	parse := func(line string) (purgeOptions, error) {
+		args, err := parseArgs(command, line)
+		if err != nil {
+			return purgeOptions{}, err
+		}
+		return purgeOptionsFrom(args)
+	}
+
+	opts, err := parse(`25 --contains "Hello there" --after 2024-01-02 --attachments-only -n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := purgeOptions{
+		count:           25,
+		contains:        "hello there",
+		after:           snowflakeAt(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)),
+		attachmentsOnly: true,
+		dryRun:          true,
+	}
+	if opts != want {
+		t.Errorf("got %+v, want %+v", opts, want)
+	}
+
+	if opts, err := parse(""); err != nil || opts.count != 10 {
+		t.Errorf("got %+v, %v without arguments", opts, err)
+	}
+
+	for _, line := range []string{
+		"--contains",
+		"--before yesterday",
+		"-3",
+		"--before 2024-01-01 --after 2024-02-01",
+	} {
+		if _, err := parse(line); err == nil {
+			t.Errorf("%q didn't fail", line)
+		}
+	}
+}
+
+// fakeHistory serves a channel's history, newest first, and records deletes.
+type fakeHistory struct {
+	mu       sync.Mutex
+	messages []Message
+	deleted  []string
+	pages    int
+}
+
+func (h *fakeHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r.Method == http.MethodDelete {
+		h.deleted = append(h.deleted, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.pages++
+	before, _ := strconv.ParseUint(r.URL.Query().Get("before"), 10, 64)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	page := []Message{}
+	for _, message := range h.messages {
+		id, _ := strconv.ParseUint(message.ID, 10, 64)
+		if id < before && len(page) < limit {
+			page = append(page, message)
+		}
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func TestPurgeMessages(t *testing.T) {
+	history := new(fakeHistory)
+	for i := 250; i > 0; i-- {
+		author := "2"
+		if i%2 == 0 {
+			author = "1"
+		}
+		history.messages = append(history.messages, Message{
+			ID:      fmt.Sprint(100000000000000000 + i),
+			Content: fmt.Sprintf("message %d", i),
+			Author:  User{ID: author},
+		})
+	}
+
+	defaultREST := rest
+	rest = newTestRESTClient(t, history.ServeHTTP)
+	t.Cleanup(func() { rest = defaultREST })
+
+	setSelfUser(User{ID: "1"})
+	t.Cleanup(func() { setSelfUser(User{}) })
+
+	var progress []int
+	result, err := purgeMessages("1", 100000000000000251, purgeOptions{count: 60}, func(result purgeResult) {
+		progress = append(progress, result.deleted)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.deleted != 60 || len(history.deleted) != 60 {
+		t.Errorf("deleted %d (%d requests), want 60", result.deleted, len(history.deleted))
+	}
+	if history.pages != 2 || len(progress) != 1 {
+		t.Errorf("fetched %d pages with %d progress reports", history.pages, len(progress))
+	}
+	for _, id := range history.deleted {
+		if n, _ := strconv.Atoi(id[len(id)-3:]); n%2 != 0 {
+			t.Errorf("deleted %s, which isn't our message", id)
+		}
+	}
+
+	history.deleted = nil
+
+	result, err = purgeMessages("1", 100000000000000251, purgeOptions{count: 1000, contains: "message 1", after: 100000000000000100, dryRun: true}, func(purgeResult) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.deleted) != 0 {
+		t.Errorf("dry run deleted %d messages", len(history.deleted))
+	}
+	if result.scanned != 150 {
+		t.Errorf("scanned %d messages, want the 150 after the bound", result.scanned)
+	}
+	for _, matched := range result.matched {
+		if !strings.HasPrefix(matched.Content, "message 1") || matched.Author.ID != "1" {
+			t.Errorf("matched %+v", matched)
+		}
+	}
+	if len(result.matched) != 49 { // Our messages 102 to 198.
+		t.Errorf("matched %d messages, want 49", len(result.matched))
+	}
+}
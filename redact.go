@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minRedactedLength keeps very short values, which only turn up in tests and
+// broken configs, from blanking out unrelated output.
+const minRedactedLength = 8
+
+// redactSecrets replaces every secret the bot is running with in s.
+func redactSecrets(s string, cfg Config) string {
+	for _, secret := range secretValues(cfg) {
+		if len(secret) >= minRedactedLength {
+			s = strings.ReplaceAll(s, secret, "[redacted]")
+		}
+	}
+	return s
+}
+
+// redactOutput filters everything written to stdout and stderr, and through
+// the log package, so no secret reaches the console or a log file. It returns
+// a function that writes out what's left and puts the output back; anything
+// printed after redactOutput and before an exit is lost without it.
+func redactOutput() (restore func()) {
+	stdout, stderr := os.Stdout, os.Stderr
+
+	var wg sync.WaitGroup
+	filter := func(to *os.File) *os.File {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return to
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			copyRedacted(to, r)
+			r.Close()
+		}()
+		return w
+	}
+
+	os.Stdout, os.Stderr = filter(stdout), filter(stderr)
+	log.SetOutput(os.Stderr)
+
+	return func() {
+		if os.Stdout != stdout {
+			os.Stdout.Close()
+		}
+		if os.Stderr != stderr {
+			os.Stderr.Close()
+		}
+		wg.Wait()
+
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(os.Stderr)
+	}
+}
+
+// redactFlushDelay is how long copyRedacted holds on to text that could be
+// the start of a secret, waiting for the rest of it, before writing it out.
+const redactFlushDelay = 100 * time.Millisecond
+
+// copyRedacted copies r to w, redacting secrets. Text is written as soon as
+// it's read, so prompts and output without a newline show straight away;
+// only an end that could be the start of a secret waits for what comes next,
+// and not for longer than redactFlushDelay. If the rest never comes, a long
+// enough start is still redacted.
+func copyRedacted(w io.Writer, r io.Reader) {
+	chunks := make(chan string)
+	go func() {
+		defer close(chunks)
+
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				chunks <- string(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var held string
+	var flush <-chan time.Time
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if held != "" {
+					io.WriteString(w, redactHeld(held))
+				}
+				return
+			}
+
+			var out string
+			out, held = splitRedacted(held+chunk, currentConfig())
+			if out != "" {
+				io.WriteString(w, out)
+			}
+
+			flush = nil
+			if held != "" {
+				flush = time.After(redactFlushDelay)
+			}
+
+		case <-flush:
+			io.WriteString(w, redactHeld(held))
+			held, flush = "", nil
+		}
+	}
+}
+
+// splitRedacted redacts s, and splits off the end of it that could be the
+// start of a secret so that it can wait for the rest.
+func splitRedacted(s string, cfg Config) (out, held string) {
+	s = redactSecrets(s, cfg)
+
+	n := 0
+	for _, secret := range secretValues(cfg) {
+		if len(secret) < minRedactedLength {
+			continue
+		}
+		for i := min(len(secret)-1, len(s)); i > n; i-- {
+			if strings.HasSuffix(s, secret[:i]) {
+				n = i
+				break
+			}
+		}
+	}
+
+	return s[:len(s)-n], s[len(s)-n:]
+}
+
+// redactHeld returns what to write for text splitRedacted held back, which is
+// always the start of a secret, when the rest of the secret didn't follow.
+// Most of a secret gives too much of it away to write out, so anything as
+// long as minRedactedLength is redacted.
+func redactHeld(held string) string {
+	if len(held) >= minRedactedLength {
+		return "[redacted]"
+	}
+	return held
+}
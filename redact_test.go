@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	cfg := Config{Token: "secret-token", GeminiAPIKey: "short", Prefix: "&"}
+
+	got := redactSecrets("identify with secret-token, key short & prefix &", cfg)
+	if want := "identify with [redacted], key short & prefix &"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCopyRedacted(t *testing.T) {
+	saved := configStore
+	configStore = NewConfigStore("")
+	defer func() { configStore = saved }()
+	configStore.Replace(Config{Token: "secret-token"})
+
+	var out strings.Builder
+	copyRedacted(&out, strings.NewReader("one secret-token\ntwo secret-token"))
+
+	if want := "one [redacted]\ntwo [redacted]"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+// chanWriter sends everything written to it on the channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestCopyRedactedFlushesPartialLines(t *testing.T) {
+	saved := configStore
+	configStore = NewConfigStore("")
+	defer func() { configStore = saved }()
+	configStore.Replace(Config{Token: "secret-token"})
+
+	r, w := io.Pipe()
+	out := make(chanWriter)
+	done := make(chan struct{})
+	go func() {
+		copyRedacted(out, r)
+		close(done)
+	}()
+
+	expect := func(want string, within time.Duration) {
+		t.Helper()
+		select {
+		case got := <-out:
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		case <-time.After(within):
+			t.Fatalf("%q wasn't written", want)
+		}
+	}
+
+	// A prompt shows without waiting for a newline.
+	io.WriteString(w, "Passphrase: ")
+	expect("Passphrase: ", time.Second)
+
+	// The start of a secret waits for the rest of it.
+	io.WriteString(w, "key secret-")
+	expect("key ", time.Second)
+	io.WriteString(w, "token!")
+	expect("[redacted]!", time.Second)
+
+	// Text that only looks like the start of one is written after a while.
+	io.WriteString(w, "secret")
+	expect("secret", time.Second)
+
+	// But not when it's long enough to give most of a secret away.
+	io.WriteString(w, "secret-tok")
+	expect("[redacted]", time.Second)
+	io.WriteString(w, "\n")
+	expect("\n", time.Second)
+
+	w.Close()
+	<-done
+}
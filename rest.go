@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discordAPIBase = "https://discord.com/api/v10"
+	restUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/143.0.0.0 Safari/537.36"
+
+	// restMaxRetries is how many times a request is retried after a 429 or
+	// a 5xx before its error is returned.
+	restMaxRetries = 3
+)
+
+// JSON error codes Discord returns that callers commonly need to tell apart.
+const (
+	errorCodeUnknownChannel     = 10003
+	errorCodeUnknownMessage     = 10008
+	errorCodeMissingAccess      = 50001
+	errorCodeMissingPermissions = 50013
+)
+
+// Sentinels an APIError matches with errors.Is.
+var (
+	ErrUnknownChannel     = errors.New("unknown channel")
+	ErrUnknownMessage     = errors.New("unknown message")
+	ErrMissingAccess      = errors.New("missing access")
+	ErrMissingPermissions = errors.New("missing permissions")
+	ErrRateLimited        = errors.New("rate limited")
+)
+
+// APIError is returned by RESTClient.Do when Discord answers with a status
+// outside 2xx, after any retries.
+type APIError struct {
+	Method     string `json:"-"`
+	Path       string `json:"-"`
+	StatusCode int    `json:"-"`
+	Code       int    `json:"code"`    // Discord's JSON error code, or 0 if the body had none.
+	Message    string `json:"message"` // Discord's description of the error, if the body had one.
+}
+
+func (err *APIError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("%s %s failed (%d)", err.Method, err.Path, err.StatusCode)
+	}
+
+	return fmt.Sprintf("%s %s failed (%d): %s (code %d)", err.Method, err.Path, err.StatusCode, err.Message, err.Code)
+}
+
+func (err *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnknownChannel:
+		return err.Code == errorCodeUnknownChannel
+	case ErrUnknownMessage:
+		return err.Code == errorCodeUnknownMessage
+	case ErrMissingAccess:
+		return err.Code == errorCodeMissingAccess
+	case ErrMissingPermissions:
+		return err.Code == errorCodeMissingPermissions
+	case ErrRateLimited:
+		return err.StatusCode == http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+// rest is the client every Discord REST call goes through.
+var rest = NewRESTClient(discordAPIBase, func() string { return currentConfig().Token }).WithBudget(sendBudget)
+
+// RESTClient sends requests to Discord's REST API. It follows the rate limit
+// headers Discord returns: requests that share a bucket are queued behind
+// each other and wait out an exhausted bucket, a global limit pauses every
+// request, and 429s and 5xx responses are retried after Retry-After.
+type RESTClient struct {
+	baseURL string
+	token   func() string
+	client  *http.Client
+	budget  *SendBudget // Taken from by every request but GETs and typing, if set.
+
+	mu          sync.Mutex
+	buckets     map[string]*restBucket
+	hashes      map[string]string // route template -> X-RateLimit-Bucket
+	globalReset time.Time
+}
+
+// restBucket is one rate limit bucket. Its mutex is held for the whole of a
+// request, which is what queues requests that share the bucket.
+type restBucket struct {
+	mu        sync.Mutex
+	remaining int
+	reset     time.Time
+}
+
+// restRoute identifies the rate limit a request falls under. Discord keeps
+// separate limits per channel, guild and webhook, so those IDs are kept in
+// major while every ID is replaced in template.
+type restRoute struct {
+	template string
+	major    string
+}
+
+func NewRESTClient(baseURL string, token func() string) *RESTClient {
+	return &RESTClient{
+		baseURL: baseURL,
+		token:   token,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		buckets: make(map[string]*restBucket),
+		hashes:  make(map[string]string),
+	}
+}
+
+// WithBudget makes every request but GETs and typing indicators wait for
+// budget, on top of Discord's own rate limits, and returns c.
+func (c *RESTClient) WithBudget(budget *SendBudget) *RESTClient {
+	c.budget = budget
+	return c
+}
+
+// Do sends a request to path, relative to the API base, with body encoded as
+// JSON if it isn't nil, and decodes a JSON response into result if it isn't
+// nil. Responses outside 2xx are returned as an *APIError.
+func (c *RESTClient) Do(method, path string, body, result any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal %s %s body: %w", method, path, err)
+		}
+	}
+
+	return c.do(method, path, payload, "application/json", result)
+}
+
+// File is an attachment uploaded from memory with DoMultipart.
+type File struct {
+	Name        string
+	ContentType string // Detected from Data if empty.
+	Data        []byte
+}
+
+// DoMultipart is Do for requests that upload files. body is sent as the
+// payload_json part and file i as files[i], which body's attachments refer
+// to by that index.
+func (c *RESTClient) DoMultipart(method, path string, body any, files []File, result any) error {
+	var payload bytes.Buffer
+	writer := multipart.NewWriter(&payload)
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s %s body: %w", method, path, err)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="payload_json"`)
+		header.Set("Content-Type", "application/json")
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		part.Write(data)
+	}
+
+	for i, file := range files {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = http.DetectContentType(file.Data)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[%d]"; filename=%q`, i, file.Name))
+		header.Set("Content-Type", contentType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		part.Write(file.Data)
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return c.do(method, path, payload.Bytes(), writer.FormDataContentType(), result)
+}
+
+func (c *RESTClient) do(method, path string, payload []byte, contentType string, result any) error {
+	route := parseRoute(method, path)
+	if c.budget != nil && route.budgeted() {
+		c.budget.Wait()
+	}
+
+	bucket := c.bucket(route)
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		c.waitForLimits(bucket)
+
+		resp, err := c.send(method, path, payload, contentType)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s %s: failed to read response: %w", method, path, err)
+		}
+
+		c.updateLimits(route, bucket, resp, respBody)
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retryable && attempt < restMaxRetries {
+			delay := retryAfter(resp, respBody, attempt)
+			fmt.Printf("%s %s returned %d, retrying in %v\n", method, path, resp.StatusCode, delay)
+			time.Sleep(delay)
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			apiErr := &APIError{Method: method, Path: path, StatusCode: resp.StatusCode}
+			json.Unmarshal(respBody, apiErr) // Not every error response has a JSON body.
+			return apiErr
+		}
+
+		if result != nil && len(respBody) > 0 {
+			if err := json.Unmarshal(respBody, result); err != nil {
+				return fmt.Errorf("failed to parse %s %s response: %w", method, path, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+func (c *RESTClient) send(method, path string, payload []byte, contentType string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", c.token())
+	req.Header.Set("User-Agent", restUserAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return c.client.Do(req)
+}
+
+// bucket returns the bucket for route, keyed by Discord's bucket hash once a
+// response has told us which bucket the route belongs to.
+func (c *RESTClient) bucket(route restRoute) *restBucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := route.template + "|" + route.major
+	if hash, ok := c.hashes[route.template]; ok {
+		key = hash + "|" + route.major
+	}
+
+	bucket, ok := c.buckets[key]
+	if !ok {
+		bucket = &restBucket{remaining: 1}
+		c.buckets[key] = bucket
+	}
+
+	return bucket
+}
+
+// waitForLimits sleeps until neither the global limit nor bucket, which the
+// caller holds, stops the next request.
+func (c *RESTClient) waitForLimits(bucket *restBucket) {
+	c.mu.Lock()
+	globalReset := c.globalReset
+	c.mu.Unlock()
+
+	if wait := time.Until(globalReset); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	if bucket.remaining <= 0 {
+		if wait := time.Until(bucket.reset); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
+
+func (c *RESTClient) updateLimits(route restRoute, bucket *restBucket, resp *http.Response, body []byte) {
+	header := resp.Header
+
+	if remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil {
+		bucket.remaining = remaining
+	} else {
+		bucket.remaining = 1 // Routes without limit headers aren't limited.
+	}
+
+	if resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		bucket.reset = time.Now().Add(time.Duration(resetAfter * float64(time.Second)))
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		bucket.remaining = 0
+		reset := time.Now().Add(retryAfter(resp, body, 0))
+
+		if header.Get("X-RateLimit-Global") == "true" || isGlobalRateLimit(body) {
+			c.mu.Lock()
+			c.globalReset = reset
+			c.mu.Unlock()
+		} else if reset.After(bucket.reset) {
+			bucket.reset = reset
+		}
+	}
+
+	if hash := header.Get("X-RateLimit-Bucket"); hash != "" {
+		c.mu.Lock()
+		if _, ok := c.hashes[route.template]; !ok {
+			c.hashes[route.template] = hash
+			// Later requests look the bucket up by its hash, so this one
+			// has to be reachable under it too.
+			key := hash + "|" + route.major
+			if _, ok := c.buckets[key]; !ok {
+				c.buckets[key] = bucket
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// retryAfter returns how long to wait before retrying a 429 or 5xx response,
+// preferring the wait Discord asked for.
+func retryAfter(resp *http.Response, body []byte, attempt int) time.Duration {
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	var rateLimit struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &rateLimit) == nil && rateLimit.RetryAfter > 0 {
+		return time.Duration(rateLimit.RetryAfter * float64(time.Second))
+	}
+
+	return time.Second << attempt
+}
+
+func isGlobalRateLimit(body []byte) bool {
+	var rateLimit struct {
+		Global bool `json:"global"`
+	}
+	return json.Unmarshal(body, &rateLimit) == nil && rateLimit.Global
+}
+
+// parseRoute works out which rate limit route a request belongs to.
+func parseRoute(method, path string) restRoute {
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(path, "/")
+
+	var major []string
+	for i := 1; i < len(parts); i++ {
+		switch parts[i-1] {
+		case "channels", "guilds", "webhooks":
+			major = append(major, parts[i])
+			parts[i] = "{" + parts[i-1] + "}"
+			continue
+		case "reactions":
+			// Every emoji and user under a message's reactions shares a limit.
+			parts = append(parts[:i], "{reaction}")
+		}
+
+		if isSnowflake(parts[i]) {
+			parts[i] = "{id}"
+		}
+	}
+
+	return restRoute{
+		template: method + " " + strings.Join(parts, "/"),
+		major:    strings.Join(major, "/"),
+	}
+}
+
+// budgeted reports whether requests on the route take from the send budget.
+// Reads don't, and neither does the typing indicator: it goes out before
+// every command, so it would halve the budget while showing nothing anyone
+// keeps.
+func (route restRoute) budgeted() bool {
+	return !strings.HasPrefix(route.template, http.MethodGet+" ") &&
+		route.template != "POST /channels/{channels}/typing"
+}
+
+func isSnowflake(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil && len(s) >= 15
+}
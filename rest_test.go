@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRESTClient(t *testing.T, handler http.HandlerFunc) *RESTClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewRESTClient(server.URL, func() string { return "token" })
+}
+
+func TestRESTClientRetries429(t *testing.T) {
+	var requests atomic.Int32
+	var first atomic.Int64
+
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			first.Store(time.Now().UnixNano())
+			w.Header().Set("Retry-After", "0.1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"message":"You are being rate limited.","retry_after":0.1,"global":false}`)
+			return
+		}
+
+		if wait := time.Since(time.Unix(0, first.Load())); wait < 100*time.Millisecond {
+			t.Errorf("retried after %v, before Retry-After", wait)
+		}
+		io.WriteString(w, `{"id":"1"}`)
+	})
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := client.Do("POST", "/channels/111111111111111111/messages", map[string]string{"content": "hi"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ID != "1" || requests.Load() != 2 {
+		t.Errorf("got id %q after %d requests", result.ID, requests.Load())
+	}
+}
+
+func TestRESTClientRetries5xx(t *testing.T) {
+	var requests atomic.Int32
+
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	if err := client.Do("GET", "/users/@me", nil, nil); err == nil {
+		t.Fatal("got no error for a failing route")
+	}
+	if n := requests.Load(); n != restMaxRetries+1 {
+		t.Errorf("sent %d requests, want %d", n, restMaxRetries+1)
+	}
+}
+
+func TestRESTClientWaitsForExhaustedBucket(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+
+		w.Header().Set("X-RateLimit-Bucket", "abc")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "0.1")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"222222222222222222", "333333333333333333"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := client.Do("DELETE", "/channels/111111111111111111/messages/"+id, nil, nil); err != nil {
+				t.Error(err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(times) != 2 {
+		t.Fatalf("got %d requests", len(times))
+	}
+	if gap := times[1].Sub(times[0]); gap < 100*time.Millisecond {
+		t.Errorf("second request sent %v after the first, before the bucket reset", gap)
+	}
+}
+
+func TestRESTClientGlobalLimit(t *testing.T) {
+	var limited atomic.Bool
+	var limitedAt atomic.Int64
+
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/@me" && !limited.Swap(true) {
+			limitedAt.Store(time.Now().UnixNano())
+			w.Header().Set("X-RateLimit-Global", "true")
+			w.Header().Set("Retry-After", "0.1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		if r.URL.Path == "/gateway" && time.Since(time.Unix(0, limitedAt.Load())) < 100*time.Millisecond {
+			t.Error("request on another route sent during the global limit")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Do("GET", "/users/@me", nil, nil)
+	}()
+
+	for !limited.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // Let the 429 be recorded.
+
+	if err := client.Do("GET", "/gateway", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         restRoute
+	}{
+		{"POST", "/channels/111111111111111111/messages", restRoute{"POST /channels/{channels}/messages", "111111111111111111"}},
+		{"DELETE", "/channels/111111111111111111/messages/222222222222222222", restRoute{"DELETE /channels/{channels}/messages/{id}", "111111111111111111"}},
+		{"GET", "/channels/111111111111111111/messages?limit=50", restRoute{"GET /channels/{channels}/messages", "111111111111111111"}},
+		{"PUT", "/channels/111111111111111111/messages/222222222222222222/reactions/%F0%9F%94%A5/@me", restRoute{"PUT /channels/{channels}/messages/{id}/reactions/{reaction}", "111111111111111111"}},
+		{"PATCH", "/users/@me/settings", restRoute{"PATCH /users/@me/settings", ""}},
+	}
+
+	for _, test := range tests {
+		if got := parseRoute(test.method, test.path); got != test.want {
+			t.Errorf("parseRoute(%q, %q) = %+v, want %+v", test.method, test.path, got, test.want)
+		}
+	}
+}
+
+func TestRESTClientBudget(t *testing.T) {
+	budget := NewSendBudget(60, 10)
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{}`)
+	}).WithBudget(budget)
+
+	requests := []struct {
+		method, path string
+	}{
+		{"GET", "/channels/111111111111111111/messages?limit=50"},
+		{"POST", "/channels/111111111111111111/typing"},
+		{"POST", "/channels/111111111111111111/messages"},
+		{"PATCH", "/channels/111111111111111111/messages/222222222222222222"},
+	}
+	for _, request := range requests {
+		if err := client.Do(request.method, request.path, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the message and the edit count.
+	if sent := budget.Stats().Sent; sent != 2 {
+		t.Errorf("took %d tokens, want 2", sent)
+	}
+}
+
+func TestRESTClientAPIError(t *testing.T) {
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message":"Missing Permissions","code":50013}`)
+	})
+
+	err := client.Do("DELETE", "/channels/111111111111111111/messages/222222222222222222", nil, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.Code != errorCodeMissingPermissions || apiErr.Message != "Missing Permissions" {
+		t.Errorf("got %+v", apiErr)
+	}
+	if !errors.Is(err, ErrMissingPermissions) || errors.Is(err, ErrUnknownMessage) || errors.Is(err, ErrRateLimited) {
+		t.Errorf("%v matched the wrong sentinels", err)
+	}
+	if got := describeError(err); got != "Missing permissions" {
+		t.Errorf("describeError = %q", got)
+	}
+}
+
+func TestRESTClientDoMultipart(t *testing.T) {
+	client := newTestRESTClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+
+		var payload MessageSend
+		if err := json.Unmarshal([]byte(r.FormValue("payload_json")), &payload); err != nil {
+			t.Error(err)
+			return
+		}
+		if payload.Content != "log" || payload.MessageReference.MessageID != "2" || len(payload.Attachments) != 1 || payload.Attachments[0].Filename != "log.txt" {
+			t.Errorf("got payload %+v", payload)
+		}
+
+		file, header, err := r.FormFile("files[0]")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "log.txt" || string(data) != "line 1\nline 2\n" {
+			t.Errorf("got file %q: %q", header.Filename, data)
+		}
+
+		io.WriteString(w, `{"id":"3"}`)
+	})
+
+	msg := NewMessage("log").ReplyTo(Message{ID: "2", ChannelID: "1"}).File("log.txt", []byte("line 1\nline 2\n"))
+
+	var sent Message
+	if err := client.DoMultipart("POST", "/channels/1/messages", msg, msg.files, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.ID != "3" {
+		t.Errorf("got id %q", sent.ID)
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/rikkuness/discord-rpc/ipc"
 )
 
+// Config is the part of the bot's config.json the rpc client reads. Run the
+// bot once to migrate an older config.json to this layout.
 type Config struct {
+	Version int    `json:"version"`
 	Token   string `json:"token"`
-	OwnerID int64  `json:"owner_id"`
+	OwnerID string `json:"owner_id"`
 	Prefix  string `json:"prefix"`
 	RPC     struct {
 		Enabled     bool   `json:"enabled"`
@@ -24,6 +27,9 @@ type Config struct {
 	} `json:"rpc"`
 }
 
+// configVersion is the config layout version the rpc client reads.
+const configVersion = 1
+
 type Client struct {
 	ClientID string
 	Socket   *ipc.Socket
@@ -158,6 +164,10 @@ func loadConfig() (Config, error) {
 		return config, fmt.Errorf("Error parsing config file: %w", err)
 	}
 
+	if config.Version != configVersion {
+		return config, fmt.Errorf("config.json is version %d, but the rpc client reads version %d; run the bot once to migrate it", config.Version, configVersion)
+	}
+
 	return config, nil
 }
 
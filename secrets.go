@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// secretsName is the secrets file's name, next to the config file unless
+	// --secrets or RUNE_SECRETS says otherwise.
+	secretsName = "secrets.enc"
+
+	secretsVersion    = 1
+	secretsKDF        = "pbkdf2-sha256"
+	secretsIterations = 600000
+)
+
+// errWrongPassphrase is returned for a secrets file that doesn't decrypt,
+// which GCM can't tell apart from one that's been tampered with.
+var errWrongPassphrase = errors.New("the passphrase is wrong, or the secrets file is damaged")
+
+// secretsFile is the secrets file as it's saved. The secrets are encrypted
+// with AES-256-GCM under a key derived from the passphrase.
+type secretsFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Secrets are the settings read from the secrets file, by config key, and
+// the passphrase they were read with.
+type Secrets struct {
+	values     map[string]string
+	passphrase string
+}
+
+// secretKeys returns the config keys that hold secrets, which are the fields
+// tagged secret:"true".
+func secretKeys() []string {
+	var keys []string
+
+	t := reflect.TypeOf(Config{})
+	for key, index := range configFields() {
+		if t.Field(index).Tag.Get("secret") == "true" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// secretValues returns the secrets that are set in cfg, by config key. The
+// placeholders in a new config file don't count.
+func secretValues(cfg Config) map[string]string {
+	values := make(map[string]string)
+
+	v := reflect.ValueOf(cfg)
+	for _, key := range secretKeys() {
+		value := v.Field(configFields()[key]).String()
+		if value != "" && !strings.HasPrefix(value, "YOUR_") {
+			values[key] = value
+		}
+	}
+
+	return values
+}
+
+// secretsPathFor returns where the secrets file for the config file at
+// configFile is.
+func secretsPathFor(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), secretsName)
+}
+
+// loadSecrets reads and decrypts the secrets file at path, asking for the
+// passphrase if RUNE_PASSPHRASE isn't set. Without a secrets file there are
+// no secrets, and no error.
+func loadSecrets(path string) (*Secrets, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Secrets{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := getPassphrase(fmt.Sprintf("Passphrase for %s: ", path), false)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := decryptSecrets(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Secrets{values, passphrase}, nil
+}
+
+// apply lays the secrets over cfg.
+func (secrets *Secrets) apply(cfg *Config) {
+	for key, value := range secrets.values {
+		setConfigValue(cfg, key, value)
+	}
+}
+
+// getPassphrase returns RUNE_PASSPHRASE, or asks for a passphrase on the
+// terminal. A new passphrase is asked for twice.
+func getPassphrase(prompt string, confirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv("RUNE_PASSPHRASE"); ok {
+		return passphrase, nil
+	}
+
+	passphrase, err := readPassphrase(prompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase can't be empty")
+	}
+
+	if confirm {
+		again, err := readPassphrase("Once more: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("the passphrases don't match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+func secretsKey(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecrets returns the secrets file for values.
+func encryptSecrets(values map[string]string, passphrase string) ([]byte, error) {
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	file := secretsFile{
+		Version:    secretsVersion,
+		KDF:        secretsKDF,
+		Iterations: secretsIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := secretsKey(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return nil, err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plaintext, nil)
+
+	return json.MarshalIndent(file, "", "    ")
+}
+
+// decryptSecrets returns the secrets in a secrets file, by config key.
+func decryptSecrets(data []byte, passphrase string) (map[string]string, error) {
+	var file secretsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("can't read the secrets file: %w", err)
+	}
+	if file.Version != secretsVersion || file.KDF != secretsKDF || file.Iterations < 1 {
+		return nil, fmt.Errorf("the secrets file is version %d with %s, but this build only reads version %d with %s", file.Version, file.KDF, secretsVersion, secretsKDF)
+	}
+
+	gcm, err := secretsKey(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, errWrongPassphrase
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(plaintext, &values); err != nil {
+		return nil, fmt.Errorf("can't read the secrets file: %w", err)
+	}
+
+	secret := make(map[string]bool)
+	for _, key := range secretKeys() {
+		secret[key] = true
+	}
+	for key := range values {
+		if !secret[key] {
+			return nil, fmt.Errorf("the secrets file has %s, which isn't a secret setting", key)
+		}
+	}
+
+	return values, nil
+}
+
+// moveSecrets is --encrypt-secrets. It saves the secrets the bot is running
+// with to the secrets file at path, encrypted, and takes them out of the
+// config file. It returns the process's exit code.
+func moveSecrets(path string, secrets *Secrets) int {
+	values := secretValues(currentConfig())
+	if len(values) == 0 {
+		fmt.Println("There are no secrets to encrypt")
+		return 1
+	}
+
+	// Secrets already in the file keep its passphrase.
+	passphrase := secrets.passphrase
+	if passphrase == "" {
+		var err error
+		passphrase, err = getPassphrase(fmt.Sprintf("New passphrase for %s: ", path), true)
+		if err != nil {
+			fmt.Println("Error reading passphrase:", err)
+			return 1
+		}
+	}
+
+	data, err := encryptSecrets(values, passphrase)
+	if err != nil {
+		fmt.Println("Error encrypting secrets:", err)
+		return 1
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		fmt.Println("Error writing secrets file:", err)
+		return 1
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := strings.Join(keys, ", ")
+	fmt.Printf("Saved %s to %s\n", list, path)
+
+	// Without a config file the secrets came from the environment, and
+	// there's nothing to take them out of.
+	if _, err := os.Stat(configStore.Path()); err != nil {
+		return 0
+	}
+
+	err = configStore.Update(func(cfg *Config) error {
+		v := reflect.ValueOf(cfg).Elem()
+		for _, key := range secretKeys() {
+			v.Field(configFields()[key]).SetString("")
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Removed %s from %s\n", list, configStore.Path())
+
+	return 0
+}
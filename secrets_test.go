@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecretKeys(t *testing.T) {
+	if keys, want := secretKeys(), []string{"gemini_api_key", "token"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+
+	cfg := Config{Token: "secret-token", GeminiAPIKey: "YOUR_GEMINI_API_KEY", Prefix: "&"}
+	if values, want := secretValues(cfg), map[string]string{"token": "secret-token"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
+
+func TestEncryptSecrets(t *testing.T) {
+	values := map[string]string{"token": "secret-token", "gemini_api_key": "key"}
+
+	data, err := encryptSecrets(values, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Fatal("the secrets file has the token in it")
+	}
+
+	decrypted, err := decryptSecrets(data, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decrypted, values) {
+		t.Errorf("got %v, want %v", decrypted, values)
+	}
+
+	if _, err := decryptSecrets(data, "hunter3"); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("wrong passphrase gave %v", err)
+	}
+
+	// The same secrets encrypt differently every time.
+	again, err := encryptSecrets(values, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) == string(data) {
+		t.Error("secrets were encrypted with the same salt and nonce twice")
+	}
+
+	notSecret, err := encryptSecrets(map[string]string{"prefix": "!"}, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decryptSecrets(notSecret, "hunter2"); err == nil {
+		t.Error("a setting that isn't a secret was read from the secrets file")
+	}
+}
+
+func TestMoveSecrets(t *testing.T) {
+	t.Setenv("RUNE_PASSPHRASE", "hunter2")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	secretsPath := filepath.Join(dir, secretsName)
+
+	saved := configStore
+	configStore = NewConfigStore(path)
+	defer func() { configStore = saved }()
+
+	if err := configStore.Update(func(cfg *Config) error {
+		*cfg = defaultConfig()
+		cfg.Token = "secret-token"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := moveSecrets(secretsPath, &Secrets{}); code != 0 {
+		t.Fatalf("exited with %d", code)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Error("the token is still in the config file")
+	}
+
+	secrets, err := loadSecrets(secretsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets.values["token"] != "secret-token" {
+		t.Errorf("got secrets %v", secrets.values)
+	}
+
+	// With the secrets laid over it, the emptied config is still valid.
+	configStore.SetSource(path, secrets.apply)
+	if _, _, err := parseConfig(data); err != nil {
+		t.Error(err)
+	}
+}
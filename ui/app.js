@@ -133,6 +133,11 @@ function updateStatsUI(stats) {
     document.getElementById('uptime').textContent = uptimeText;
     document.getElementById('commandsHandled').textContent = stats.commands_handled.toLocaleString();
     document.getElementById('messagesLogged').textContent = stats.messages_logged.toLocaleString();
+
+    const state = stats.gateway_state || 'unknown';
+    document.getElementById('connectionStatus').textContent = state === 'ready'
+        ? 'Connected'
+        : `Gateway ${state}`;
 }
 
 // Handle auto responder toggle
@@ -8,13 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"sync"
 	"time"
 )
 
 var (
-	configMutex sync.RWMutex
-	uiPort      = "3000"
+	uiPort = "3000"
 )
 
 // SafeConfig represents config without sensitive data
@@ -30,12 +28,16 @@ type SafeConfig struct {
 
 // StatsResponse represents bot statistics
 type StatsResponse struct {
-	UptimeDays      int     `json:"uptime_days"`
-	UptimeHours     int     `json:"uptime_hours"`
-	UptimeMinutes   int     `json:"uptime_minutes"`
-	CommandsHandled int     `json:"commands_handled"`
-	MessagesLogged  int     `json:"messages_logged"`
-	MemoryUsageMB   float64 `json:"memory_usage_mb"`
+	UptimeDays      int             `json:"uptime_days"`
+	UptimeHours     int             `json:"uptime_hours"`
+	UptimeMinutes   int             `json:"uptime_minutes"`
+	CommandsHandled int             `json:"commands_handled"`
+	MessagesLogged  int             `json:"messages_logged"`
+	MemoryUsageMB   float64         `json:"memory_usage_mb"`
+	GatewayState    string          `json:"gateway_state"`
+	GatewayLatency  int64           `json:"gateway_latency_ms"`
+	EventBus        EventBusStats   `json:"event_bus"`
+	SendBudget      SendBudgetStats `json:"send_budget"`
 }
 
 // ConfigUpdateRequest represents a config update request
@@ -54,7 +56,8 @@ type StatusUpdateRequest struct {
 }
 
 func GetSafeConfig() SafeConfig {
-	configMutex.RLock()
+	config := currentConfig()
+
 	autoResponderMutex.Lock()
 	arEnabled := autoResponderEnabled
 	autoResponderMutex.Unlock()
@@ -73,36 +76,31 @@ func GetSafeConfig() SafeConfig {
 		CurrentStatus:       currentStatus,
 		AutoPressureActive:  apActiveState,
 	}
-	configMutex.RUnlock()
 
 	return cfg
 }
 
+// UpdateConfig saves the settings that are set in updates. The autoresponder
+// picks up its new state through applyConfig.
 func UpdateConfig(updates ConfigUpdateRequest) error {
-	configMutex.Lock()
-	defer configMutex.Unlock()
-
-	if updates.Prefix != nil {
-		config.Prefix = *updates.Prefix
-	}
-	if updates.AutoResponseEnabled != nil {
-		config.AutoResponseEnabled = *updates.AutoResponseEnabled
-		// sync runtime variable
-		autoResponderMutex.Lock()
-		autoResponderEnabled = config.AutoResponseEnabled
-		autoResponderMutex.Unlock()
-	}
-	if updates.AutoResponsePhrase != nil {
-		config.AutoResponsePhrase = *updates.AutoResponsePhrase
-	}
-	if updates.AutoEmojiEnabled != nil {
-		config.AutoReactEmojiEnabled = *updates.AutoEmojiEnabled
-	}
-	if updates.AutoEmoji != nil {
-		config.AutoReactEmoji = *updates.AutoEmoji
-	}
-
-	return saveConfig()
+	return configStore.Update(func(cfg *Config) error {
+		if updates.Prefix != nil {
+			cfg.Prefix = *updates.Prefix
+		}
+		if updates.AutoResponseEnabled != nil {
+			cfg.AutoResponseEnabled = *updates.AutoResponseEnabled
+		}
+		if updates.AutoResponsePhrase != nil {
+			cfg.AutoResponsePhrase = *updates.AutoResponsePhrase
+		}
+		if updates.AutoEmojiEnabled != nil {
+			cfg.AutoReactEmojiEnabled = *updates.AutoEmojiEnabled
+		}
+		if updates.AutoEmoji != nil {
+			cfg.AutoReactEmoji = *updates.AutoEmoji
+		}
+		return nil
+	})
 }
 
 func GetStats() StatsResponse {
@@ -120,6 +118,13 @@ func GetStats() StatsResponse {
 	runtime.ReadMemStats(&m)
 	memoryMB := float64(m.Alloc) / 1024 / 1024
 
+	gatewayState := GatewayClosed
+	var gatewayLatency time.Duration
+	if gateway != nil {
+		gatewayState = gateway.State()
+		gatewayLatency = gateway.Latency()
+	}
+
 	return StatsResponse{
 		UptimeDays:      days,
 		UptimeHours:     hours,
@@ -127,32 +132,42 @@ func GetStats() StatsResponse {
 		CommandsHandled: cmdHandled,
 		MessagesLogged:  msgLogged,
 		MemoryUsageMB:   memoryMB,
+		GatewayState:    gatewayState.String(),
+		GatewayLatency:  gatewayLatency.Milliseconds(),
+		EventBus:        bus.Stats(),
+		SendBudget:      sendBudget.Stats(),
 	}
 }
 
 func ToggleAutoResponder() bool {
-	autoResponderMutex.Lock()
-	defer autoResponderMutex.Unlock()
-
-	configMutex.Lock()
-	config.AutoResponseEnabled = !config.AutoResponseEnabled
-	autoResponderEnabled = config.AutoResponseEnabled
-	configMutex.Unlock()
-
-	saveConfig()
-	return autoResponderEnabled
+	var enabled bool
+	err := configStore.Update(func(cfg *Config) error {
+		cfg.AutoResponseEnabled = !cfg.AutoResponseEnabled
+		enabled = cfg.AutoResponseEnabled
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error saving config:", err)
+		return currentConfig().AutoResponseEnabled
+	}
+	return enabled
 }
 
 func ToggleAutoEmoji() bool {
-	configMutex.Lock()
-	defer configMutex.Unlock()
-
-	config.AutoReactEmojiEnabled = !config.AutoReactEmojiEnabled
-	if !config.AutoReactEmojiEnabled {
-		config.AutoReactEmoji = ""
+	var enabled bool
+	err := configStore.Update(func(cfg *Config) error {
+		cfg.AutoReactEmojiEnabled = !cfg.AutoReactEmojiEnabled
+		if !cfg.AutoReactEmojiEnabled {
+			cfg.AutoReactEmoji = ""
+		}
+		enabled = cfg.AutoReactEmojiEnabled
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error saving config:", err)
+		return currentConfig().AutoReactEmojiEnabled
 	}
-	saveConfig()
-	return config.AutoReactEmojiEnabled
+	return enabled
 }
 
 func UpdateDiscordStatus(status string, customText string) error {
@@ -306,6 +321,40 @@ func apiStopAutoPressure(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// FeatureToggleRequest represents a request to enable or disable a feature
+type FeatureToggleRequest struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+func apiGetFeatures(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(featureStates())
+}
+
+func apiToggleFeature(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	w.Header().Set("Content-Type", "application/json")
+
+	var req FeatureToggleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := setFeatureEnabled(req.Name, req.Enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":    req.Name,
+		"enabled": req.Enabled,
+		"message": fmt.Sprintf("Feature %s %s", req.Name, map[bool]string{true: "enabled", false: "disabled"}[req.Enabled]),
+	})
+}
+
 func StartUIServer(port string) {
 	if port == "" {
 		port = uiPort
@@ -387,6 +436,21 @@ func StartUIServer(port string) {
 		}
 	})
 
+	http.HandleFunc("/api/features", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			handleOptions(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			apiGetFeatures(w, r)
+		case http.MethodPost:
+			apiToggleFeature(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	uiDir := "./ui"
 	if _, err := os.Stat(uiDir); os.IsNotExist(err) {
 		os.MkdirAll(uiDir, 0755)
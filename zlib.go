@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// zlibSuffix is the Z_SYNC_FLUSH marker Discord ends every complete
+// zlib-stream message with.
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+var errZlibStreamClosed = errors.New("zlib stream closed")
+
+// zlibStream inflates the zlib-stream gateway transport. Every frame on a
+// connection belongs to one shared deflate context, so a single zlibStream
+// has to see all of a connection's frames in order.
+//
+// Inflating runs in its own goroutine reading from a pipe: the flate reader
+// treats a drained input as a broken stream, so it must be able to block
+// until the next frame arrives instead.
+type zlibStream struct {
+	pending  []byte
+	pipe     *io.PipeWriter
+	messages chan zlibResult
+}
+
+type zlibResult struct {
+	data []byte
+	err  error
+}
+
+func newZlibStream() *zlibStream {
+	reader, writer := io.Pipe()
+
+	z := &zlibStream{
+		pipe:     writer,
+		messages: make(chan zlibResult, 1),
+	}
+
+	go z.inflate(reader)
+
+	return z
+}
+
+func (z *zlibStream) inflate(reader *io.PipeReader) {
+	defer close(z.messages)
+
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		reader.CloseWithError(err)
+		z.messages <- zlibResult{err: err}
+		return
+	}
+	defer inflater.Close()
+
+	decoder := json.NewDecoder(inflater)
+	for {
+		var message json.RawMessage
+		if err := decoder.Decode(&message); err != nil {
+			reader.CloseWithError(err)
+			z.messages <- zlibResult{err: err}
+			return
+		}
+
+		z.messages <- zlibResult{data: message}
+	}
+}
+
+// Feed adds one websocket frame to the stream. It returns the inflated
+// payload once the frame completes a message, and nil while the message is
+// still incomplete.
+func (z *zlibStream) Feed(frame []byte) ([]byte, error) {
+	z.pending = append(z.pending, frame...)
+	if !bytes.HasSuffix(z.pending, zlibSuffix) {
+		return nil, nil
+	}
+
+	if _, err := z.pipe.Write(z.pending); err != nil {
+		return nil, err
+	}
+	z.pending = z.pending[:0]
+
+	result, ok := <-z.messages
+	if !ok {
+		return nil, errZlibStreamClosed
+	}
+
+	return result.data, result.err
+}
+
+// Close releases the inflater goroutine.
+func (z *zlibStream) Close() error {
+	return z.pipe.Close()
+}
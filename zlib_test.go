@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return lines
+}
+
+func TestZlibStreamRecordedFrames(t *testing.T) {
+	frames := readLines(t, "testdata/zlib-stream.frames")
+	expected := readLines(t, "testdata/zlib-stream.payloads")
+
+	stream := newZlibStream()
+	defer stream.Close()
+
+	var decoded []string
+	for i, line := range frames {
+		frame, err := hex.DecodeString(line)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+
+		payload, err := stream.Feed(frame)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if payload != nil {
+			decoded = append(decoded, string(payload))
+		}
+	}
+
+	if len(decoded) != len(expected) {
+		t.Fatalf("decoded %d payloads, want %d", len(decoded), len(expected))
+	}
+
+	for i := range expected {
+		if decoded[i] != expected[i] {
+			t.Errorf("payload %d mismatch:\n got %.200s\nwant %.200s", i, decoded[i], expected[i])
+		}
+	}
+
+	// The payloads have to survive the trip into the gateway's own type.
+	var ready WSPayload
+	if err := json.Unmarshal([]byte(decoded[1]), &ready); err != nil {
+		t.Fatal(err)
+	}
+	if ready.T != "READY" || ready.S != 1 {
+		t.Errorf("got op=%d t=%q s=%d, want READY with s=1", ready.Op, ready.T, ready.S)
+	}
+}
+
+func TestZlibStreamRejectsGarbage(t *testing.T) {
+	stream := newZlibStream()
+	defer stream.Close()
+
+	frame := append([]byte("definitely not zlib"), zlibSuffix...)
+	if _, err := stream.Feed(frame); err == nil {
+		t.Fatal("expected an error for a frame without a zlib header")
+	}
+
+	if _, err := stream.Feed(bytes.Clone(zlibSuffix)); err == nil {
+		t.Fatal("expected the stream to stay broken after a bad frame")
+	}
+}